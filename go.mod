@@ -1,3 +1,10 @@
 module github.com/FZambia/gomodifytype
 
-go 1.16
+go 1.18
+
+require (
+	golang.org/x/term v0.11.0
+	golang.org/x/tools v0.12.0
+)
+
+require golang.org/x/sys v0.11.0 // indirect
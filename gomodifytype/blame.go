@@ -0,0 +1,66 @@
+package gomodifytype
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// gitBlame returns the git blame of the file in the --line-porcelain format.
+// It's a variable, so tests don't depend on a git checkout.
+var gitBlame = func(file string) ([]byte, error) {
+	out, err := exec.Command("git", "blame", "--line-porcelain", "--", file).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git blame failed: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// authorLines returns the set of lines of the file which were last modified
+// by -blame-author, according to git blame.
+func (c *config) authorLines() (map[int]bool, error) {
+	out, err := gitBlame(c.file)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[int]bool)
+
+	// every line starts with a header "<sha> <orig line> <final line>",
+	// followed by "key value" pairs and the tab prefixed content of the line
+	line := 0
+	author := ""
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			if author == c.blameAuthor {
+				lines[line] = true
+			}
+			line, author = 0, ""
+		case strings.HasPrefix(text, "author "):
+			author = strings.TrimPrefix(text, "author ")
+		case line == 0:
+			parts := strings.Fields(text)
+			if len(parts) < 3 {
+				return nil, fmt.Errorf("unexpected git blame header %q", text)
+			}
+			line, err = strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git blame header %q", text)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,55 @@
+package gomodifytype
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeBlame returns git blame --line-porcelain output for a file of the given
+// number of lines, attributing the lines to the authors in the map and the
+// remaining ones to bob.
+func fakeBlame(lines int, authors map[int]string) func(string) ([]byte, error) {
+	return func(string) ([]byte, error) {
+		var b strings.Builder
+		for line := 1; line <= lines; line++ {
+			author, ok := authors[line]
+			if !ok {
+				author = "bob"
+			}
+			fmt.Fprintf(&b, "%040d %d %d 1\n", line, line, line)
+			fmt.Fprintf(&b, "author %s\nauthor-mail <%s@example.com>\nsummary change\nfilename x.go\n", author, author)
+			fmt.Fprintf(&b, "\tline %d\n", line)
+		}
+		return []byte(b.String()), nil
+	}
+}
+
+func TestBlameAuthor(t *testing.T) {
+	defer func(blame func(string) ([]byte, error)) { gitBlame = blame }(gitBlame)
+	gitBlame = fakeBlame(9, map[int]string{5: "alice", 7: "alice"})
+
+	cfg := &config{
+		file:        filepath.Join(fixtureDir, "field_stride.input"),
+		structName:  "foo",
+		from:        "string",
+		to:          "[]byte",
+		blameAuthor: "alice",
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ch := range cfg.changes {
+		got = append(got, ch.Field)
+	}
+
+	want := []string{"Value0", "Value1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changed fields %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package gomodifytype
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// splitComments are the comments of a split group, copied onto a field split
+// off from it.
+type splitComments struct {
+	field        *ast.Field
+	doc, comment *ast.CommentGroup
+}
+
+// insertSplitComments inserts the comments of split groups into the
+// formatted source. The split off fields have no place in the original file,
+// so the printer can't put comments next to them. The printed file has the
+// same fields in the same order as the rewritten one, which finds them in
+// the source.
+func insertSplitComments(src []byte, file ast.Node, comments []splitComments) ([]byte, error) {
+	fset := token.NewFileSet()
+	printed, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[*ast.Field]int)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if f, ok := n.(*ast.Field); ok {
+			index[f] = len(index)
+		}
+		return true
+	})
+
+	var fields []*ast.Field
+	ast.Inspect(printed, func(n ast.Node) bool {
+		if f, ok := n.(*ast.Field); ok {
+			fields = append(fields, f)
+		}
+		return true
+	})
+
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var insertions []insertion
+	tokFile := fset.File(printed.Pos())
+	for _, sc := range comments {
+		i, ok := index[sc.field]
+		if !ok || i >= len(fields) {
+			continue
+		}
+		f := fields[i]
+
+		if sc.doc != nil {
+			lineStart := tokFile.Offset(tokFile.LineStart(fset.Position(f.Pos()).Line))
+			indent := string(src[lineStart:fset.Position(f.Pos()).Offset])
+			var doc strings.Builder
+			for _, comment := range sc.doc.List {
+				doc.WriteString(comment.Text + "\n" + indent)
+			}
+			insertions = append(insertions, insertion{offset: fset.Position(f.Pos()).Offset, text: doc.String()})
+		}
+
+		if sc.comment != nil {
+			texts := make([]string, len(sc.comment.List))
+			for j, comment := range sc.comment.List {
+				texts[j] = comment.Text
+			}
+			insertions = append(insertions, insertion{offset: fset.Position(f.End()).Offset, text: " " + strings.Join(texts, " ")})
+		}
+	}
+
+	// inserted from the end, so the offsets of the others stay valid
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].offset > insertions[j].offset
+	})
+	out := append([]byte(nil), src...)
+	for _, ins := range insertions {
+		out = append(out[:ins.offset], append([]byte(ins.text), out[ins.offset:]...)...)
+	}
+
+	// the inserted comments are aligned with the others
+	return format.Source(out)
+}
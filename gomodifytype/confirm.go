@@ -0,0 +1,71 @@
+package gomodifytype
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Confirmation modes of -confirm.
+const (
+	// confirmFile asks before the rewritten file is written
+	confirmFile = "file"
+	// confirmField asks before each field type change
+	confirmField = "field"
+)
+
+// ask prints the question to stderr and reads the answer from stdin. Only
+// "y" and "yes" are taken as an approval, anything else, including the end of
+// the input, declines.
+func (c *config) ask(format string, args ...interface{}) bool {
+	w := c.stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	_, _ = fmt.Fprintf(w, format+" [y/N] ", args...)
+
+	c.initAnswers()
+	answer, err := c.answers.ReadString('\n')
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(w)
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// initAnswers sets up the reader of the answers from stdin, if it's not set
+// up yet.
+func (c *config) initAnswers() {
+	if c.answers != nil {
+		return
+	}
+
+	var r io.Reader = c.stdin
+	if r == nil {
+		r = os.Stdin
+	}
+	c.answers = bufio.NewReader(r)
+}
+
+// confirmChange asks whether the change should be made, showing the field
+// type before and after. The names of a field group are asked for at once.
+func (c *config) confirmChange(records []change) bool {
+	names := make([]string, 0, len(records))
+	for _, ch := range records {
+		names = append(names, ch.Field)
+	}
+
+	ch := records[0]
+	field := strings.Join(names, ", ")
+	if ch.Struct != "" {
+		field = ch.Struct + "." + field
+	}
+	return c.ask("%s: change %s\n\t- %s\n\t+ %s\napply?", ch.position(), field, ch.From, ch.To)
+}
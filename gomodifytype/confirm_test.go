@@ -0,0 +1,95 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfirmField(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_stride.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "[]byte",
+		confirm:    confirmField,
+		stdin:      strings.NewReader("y\nn\nyes\n\nN\n"),
+		stderr:     &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ch := range cfg.changes {
+		got = append(got, ch.Field)
+	}
+	want := []string{"Key0", "Key1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changed fields %v, want %v", got, want)
+	}
+
+	if !strings.Contains(out, "Key1   []byte\n\tValue1 string\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	if n := strings.Count(stderr.String(), "apply? [y/N] "); n != 5 {
+		t.Errorf("expected 5 prompts, got %d:\n%s", n, stderr.String())
+	}
+}
+
+func TestConfirmFile(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join(fixtureDir, "field_type_modify.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := ioutil.ReadFile(filepath.Join(fixtureDir, "field_type_modify.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for answer, want := range map[string][]byte{"n\n": src, "y\n": golden} {
+		file := filepath.Join(t.TempDir(), "foo.go")
+		if err := ioutil.WriteFile(file, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &config{
+			file:       file,
+			write:      true,
+			structName: "foo",
+			fieldName:  "bar",
+			from:       "string",
+			to:         "[]byte",
+			confirm:    confirmFile,
+			stdin:      strings.NewReader(answer),
+			stderr:     ioutil.Discard,
+		}
+
+		if err := cfg.validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := cfg.process(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("answer %q: got file:\n%s\nwant:\n%s", answer, got, want)
+		}
+	}
+}
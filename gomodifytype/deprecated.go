@@ -0,0 +1,84 @@
+package gomodifytype
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+)
+
+// deprecatedType is an entry of a -deprecated-types file. Fields using the
+// type are changed to the replacement if there is one, and reported
+// otherwise.
+type deprecatedType struct {
+	name        string
+	replacement string
+}
+
+// parseDeprecatedTypes parses a list of deprecated types, one per line, with
+// an optional replacement:
+//
+//	# comments and empty lines are ignored
+//	ioutil.NopCloser
+//	OldID => ID
+func parseDeprecatedTypes(r io.Reader) ([]deprecatedType, error) {
+	var list []deprecatedType
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, replacement, _ := strings.Cut(line, "=>")
+		name, replacement = strings.TrimSpace(name), strings.TrimSpace(replacement)
+		if name == "" || (replacement == "" && strings.Contains(line, "=>")) {
+			return nil, fmt.Errorf("line %d: expected a type, optionally followed by => and its replacement", lineNum)
+		}
+
+		list = append(list, deprecatedType{name: name, replacement: replacement})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// loadDeprecatedTypes reads the -deprecated-types file.
+func loadDeprecatedTypes(file string) ([]deprecatedType, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list, err := parseDeprecatedTypes(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return list, nil
+}
+
+// rewriteDeprecated replaces the type of the field if it's deprecated and
+// has a replacement, or reports it if there is none.
+func (c *config) rewriteDeprecated(f *ast.Field, name string) {
+	typ := types.ExprString(c.matchedType(f))
+	for _, d := range c.deprecated {
+		if d.name != typ {
+			continue
+		}
+
+		if d.replacement == "" {
+			c.warnf(f.Pos(), "field %s uses the deprecated type %s", name, d.name)
+			return
+		}
+
+		c.replaceType(f, name, d.replacement)
+		return
+	}
+}
@@ -0,0 +1,55 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDeprecatedTypes(t *testing.T) {
+	src := "# comment\n\nOldID => ID\n  rpc.LegacyStatus  \nfunc(a int) error=>Handler\n"
+	got, err := parseDeprecatedTypes(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []deprecatedType{
+		{name: "OldID", replacement: "ID"},
+		{name: "rpc.LegacyStatus"},
+		{name: "func(a int) error", replacement: "Handler"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, src := range []string{"=> ID", "OldID =>"} {
+		if _, err := parseDeprecatedTypes(strings.NewReader(src)); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+func TestDeprecatedTypesReport(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:           filepath.Join(fixtureDir, "deprecated_types.input"),
+		structName:     "foo",
+		deprecatedFile: filepath.Join(fixtureDir, "deprecated_types.txt"),
+		stderr:         &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test-fixtures/deprecated_types.input:6:2: warning: field Status uses the deprecated type rpc.LegacyStatus\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,289 @@
+package gomodifytype
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// diffOp is a single line of an edit script turning one text into another.
+type diffOp struct {
+	kind byte // ' ' for unchanged, '-' for deleted and '+' for inserted lines
+	line string
+}
+
+// splitLines splits the text into lines without their line terminators.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the shortest edit script turning a into b, computed with
+// the Myers difference algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m
+
+	// v holds the furthest x reached on each diagonal k = x - y, trace the
+	// state of v before each round so the path can be reconstructed.
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	for d := 0; d <= offset; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b, offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackDiff(trace [][]int, a, b []string, offset int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{kind: ' ', line: a[x-1]})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			ops = append(ops, diffOp{kind: '+', line: b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{kind: '-', line: a[x-1]})
+			x--
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffContext is the number of unchanged lines around the changes of a
+// unified diff hunk.
+const diffContext = 3
+
+// unifiedDiff formats the edit script as a unified diff of the file, in the
+// same format as gofmt -d. It returns an empty string if nothing changed.
+func unifiedDiff(name string, ops []diffOp) string {
+	// the number of lines of both texts before each op
+	aLines := make([]int, len(ops)+1)
+	bLines := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if op.kind != '+' {
+			aLines[i+1]++
+		}
+		if op.kind != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+
+		// extend the hunk over changes which are close enough to share
+		// their context lines
+		last := i
+		for j := i + 1; j < len(ops) && j-last <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := last + diffContext + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "diff -u %[1]s.orig %[1]s\n--- %[1]s.orig\n+++ %[1]s\n", name)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(aLines[start], aLines[end]-aLines[start]),
+			hunkRange(bLines[start], bLines[end]-bLines[start]))
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			b.WriteByte('\n')
+		}
+
+		i = end - 1
+	}
+	return b.String()
+}
+
+// Values of -color.
+const (
+	// colorAuto colorizes the diff if it's printed to a terminal
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI escape codes of the diff colors.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// useColor reports whether the diff printed to w is colorized.
+func (c *config) useColor(w io.Writer) bool {
+	switch c.color {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// colorizeDiff colors the lines of a unified diff like git does: the file
+// header in bold, hunk headers in cyan, deleted lines in red and inserted
+// lines in green.
+func colorizeDiff(diff string) string {
+	var b strings.Builder
+	for i, line := range splitLines(diff) {
+		color := ""
+		switch {
+		case i < 3:
+			color = ansiBold
+		case strings.HasPrefix(line, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(line, "-"):
+			color = ansiRed
+		case strings.HasPrefix(line, "+"):
+			color = ansiGreen
+		}
+
+		if color == "" {
+			b.WriteString(line)
+		} else {
+			b.WriteString(color + line + ansiReset)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// hunkRange formats the one based start line and the number of lines of a
+// hunk. An empty range refers to the line before it.
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffStats holds the aggregated line counts of one or more diffs.
+type diffStats struct {
+	files      int
+	insertions int
+	deletions  int
+}
+
+// add accounts the edit script of a single file.
+func (s *diffStats) add(ops []diffOp) {
+	insertions, deletions := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			insertions++
+		case '-':
+			deletions++
+		}
+	}
+
+	if insertions == 0 && deletions == 0 {
+		return
+	}
+	s.files++
+	s.insertions += insertions
+	s.deletions += deletions
+}
+
+// merge accounts the diffs of other files.
+func (s *diffStats) merge(other diffStats) {
+	s.files += other.files
+	s.insertions += other.insertions
+	s.deletions += other.deletions
+}
+
+// String returns the summary in the same format as git diff --shortstat.
+func (s diffStats) String() string {
+	summary := fmt.Sprintf("%d %s changed", s.files, plural(s.files, "file", "files"))
+	if s.insertions > 0 || s.files == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", s.insertions, plural(s.insertions, "insertion", "insertions"))
+	}
+	if s.deletions > 0 || s.files == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", s.deletions, plural(s.deletions, "deletion", "deletions"))
+	}
+	return summary
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,204 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	test := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: " a\n b\n",
+		},
+		{
+			name: "changed",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: " a\n-b\n+x\n c\n",
+		},
+		{
+			name: "inserted and deleted",
+			a:    "a\nb\nc\n",
+			b:    "b\nc\nd\n",
+			want: "-a\n b\n c\n+d\n",
+		},
+		{
+			name: "empty",
+			a:    "",
+			b:    "a\n",
+			want: "+a\n",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			var b strings.Builder
+			for _, op := range diffLines(splitLines(ts.a), splitLines(ts.b)) {
+				b.WriteByte(op.kind)
+				b.WriteString(op.line)
+				b.WriteByte('\n')
+			}
+
+			if got := b.String(); got != ts.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestReportDiffStats(t *testing.T) {
+	test := []struct {
+		from string
+		want string
+	}{
+		{
+			from: "string",
+			want: "1 file changed, 1 insertion(+), 1 deletion(-)\n",
+		},
+		{
+			from: "int",
+			want: "0 files changed, 0 insertions(+), 0 deletions(-)\n",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.from, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cfg := &config{
+				file:            filepath.Join(fixtureDir, "field_type_modify.input"),
+				structName:      "foo",
+				fieldName:       "bar",
+				from:            ts.from,
+				to:              "[]byte",
+				reportDiffStats: true,
+				stderr:          &stderr,
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+			cfg.printDiffStats()
+
+			if got := stderr.String(); got != ts.want {
+				t.Errorf("got %q, want %q", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, fmt.Sprintf("l%d", i))
+	}
+	b = append(b, a...)
+	b[1] = "x2"
+	b = append(b[:15], b[16:]...)
+	b = append(b, "new")
+
+	test := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{
+			name: "hunks",
+			a:    a,
+			b:    b,
+			want: `diff -u foo.go.orig foo.go
+--- foo.go.orig
++++ foo.go
+@@ -1,5 +1,5 @@
+ l1
+-l2
++x2
+ l3
+ l4
+ l5
+@@ -13,8 +13,8 @@
+ l13
+ l14
+ l15
+-l16
+ l17
+ l18
+ l19
+ l20
++new
+`,
+		},
+		{
+			name: "insert into empty",
+			b:    []string{"a"},
+			want: "diff -u foo.go.orig foo.go\n--- foo.go.orig\n+++ foo.go\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "equal",
+			a:    a,
+			b:    a,
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			if got := unifiedDiff("foo.go", diffLines(ts.a, ts.b)); got != ts.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestDiffColor(t *testing.T) {
+	test := []struct {
+		color string
+		want  bool
+	}{
+		{color: colorAlways, want: true},
+		{color: colorNever, want: false},
+		// a buffer isn't a terminal
+		{color: colorAuto, want: false},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.color, func(t *testing.T) {
+			cfg := &config{
+				file:       filepath.Join(fixtureDir, "field_type_modify.input"),
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+				diff:       true,
+				color:      ts.color,
+			}
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := cfg.process()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			cfg.printFile(&buf, out)
+
+			got := buf.String()
+			if colored := strings.Contains(got, ansiGreen) && strings.Contains(got, ansiRed); colored != ts.want {
+				t.Errorf("got colored %v, want %v:\n%q", colored, ts.want, got)
+			}
+			if !ts.want && strings.Contains(got, "\x1b[") {
+				t.Errorf("expected no escape codes:\n%q", got)
+			}
+		})
+	}
+}
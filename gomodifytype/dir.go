@@ -0,0 +1,126 @@
+package gomodifytype
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// selectionError is returned by process if the selection doesn't exist in
+// the file, i.e: a -struct which isn't declared there.
+type selectionError struct {
+	err error
+}
+
+func (e *selectionError) Error() string { return e.err.Error() }
+
+func (e *selectionError) Unwrap() error { return e.err }
+
+// skipDir reports whether the directory is skipped when walking -dir. The
+// vendor directories are processed with -include-vendor only.
+func (c *config) skipDir(name string) bool {
+	if name == "vendor" {
+		return !c.includeVendor
+	}
+	return name == "testdata" || (strings.HasPrefix(name, ".") && name != ".")
+}
+
+// isTestFile reports whether the file is a Go test file.
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.go")
+}
+
+// processDir processes every Go file in the -dir tree, or only the test files
+// with -test-tables. Files which don't parse are reported and skipped, as
+// well as files the selection doesn't apply to. The changes, the declined
+// changes and the diff stats of all files are added up in c.
+func (c *config) processDir(w io.Writer) error {
+	c.changes = nil
+	c.declined = 0
+	c.stats = diffStats{}
+
+	// the files share the answers, so none are lost in the buffer of a
+	// single file
+	if c.confirm != "" {
+		c.initAnswers()
+	}
+
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != c.dir && c.skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".go" || (c.testTables && !isTestFile(path)) {
+			return nil
+		}
+
+		fc := c.forFile(path)
+		out, err := fc.process()
+
+		var syntaxErr scanner.ErrorList
+		var selErr *selectionError
+		switch {
+		case errors.As(err, &syntaxErr):
+			stderr := c.stderr
+			if stderr == nil {
+				stderr = os.Stderr
+			}
+			_, _ = fmt.Fprintf(stderr, "%s: warning: skipped, the file doesn't parse: %s\n", path, err)
+			return nil
+		case errors.As(err, &selErr):
+			return nil
+		case err != nil:
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		c.changes = append(c.changes, fc.changes...)
+		c.declined += fc.declined
+		c.stats.merge(fc.stats)
+		if len(fc.changes) != 0 {
+			fc.printFile(w, out)
+		}
+
+		name, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return err
+		}
+		return fc.emitPair(name, out)
+	})
+}
+
+// forFile returns a copy of the config processing the given file of -dir,
+// without the state of the previously processed file.
+func (c *config) forFile(file string) *config {
+	fc := *c
+	fc.dir = ""
+	fc.file = file
+	fc.src = nil
+	fc.fileSet = nil
+	fc.changes = nil
+	fc.declined = 0
+	fc.stats = diffStats{}
+	fc.blameLines = nil
+	fc.onlyLineSet = nil
+	fc.lineRanges = nil
+	fc.structRanges = nil
+	fc.replacedTypes = nil
+	fc.splitComments = nil
+	fc.importChanges = nil
+	fc.parsed = nil
+	fc.pkg = nil
+	fc.info = nil
+	fc.checkedTargets = nil
+	return &fc
+}
@@ -0,0 +1,232 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessDir(t *testing.T) {
+	const src = "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+	const want = "package foo\n\ntype foo struct {\n\tbar []byte\n}\n"
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":              src,
+		"sub/b.go":          src,
+		"sub/other.go":      "package foo\n\ntype other struct {\n\tn int\n}\n",
+		"testdata/c.go":     src,
+		"vendor/d/d.go":     src,
+		"broken.go":         "package foo\n\ntype foo struct {\n",
+		"notes.txt":         src,
+		".hidden/e.go":      src,
+		"sub/deeper/f_x.go": src,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cfg := &config{
+		dir:        dir,
+		write:      true,
+		structName: "foo",
+		from:       "string",
+		to:         "[]byte",
+		stderr:     &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.processDir(&stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten := map[string]bool{"a.go": true, "sub/b.go": true, "sub/deeper/f_x.go": true}
+	for name, content := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := content
+		if rewritten[name] {
+			expected = want
+		}
+		if string(got) != expected {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", name, got, expected)
+		}
+	}
+
+	if len(cfg.changes) != len(rewritten) {
+		t.Errorf("expected %d changes, got %+v", len(rewritten), cfg.changes)
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("nothing should be printed with -w, got:\n%s", stdout.String())
+	}
+
+	warning := filepath.Join(dir, "broken.go") + ": warning: skipped, the file doesn't parse: "
+	if !strings.HasPrefix(stderr.String(), warning) || strings.Count(stderr.String(), "\n") != 1 {
+		t.Errorf("expected a single parse warning, got:\n%s", stderr.String())
+	}
+}
+
+func TestProcessDirIncludeVendor(t *testing.T) {
+	const src = "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+	const want = "package foo\n\ntype foo struct {\n\tbar []byte\n}\n"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vendor", "d", "d.go")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{
+		dir:           dir,
+		write:         true,
+		includeVendor: true,
+		all:           true,
+		from:          "string",
+		to:            "[]byte",
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.processDir(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestProcessDirDiffStats(t *testing.T) {
+	const src = "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cfg := &config{
+		dir:             dir,
+		all:             true,
+		from:            "string",
+		to:              "[]byte",
+		reportDiffStats: true,
+		stderr:          &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.processDir(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	cfg.printDiffStats()
+
+	// the stats of the files are added up
+	want := "2 files changed, 2 insertions(+), 2 deletions(-)\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestProcessDirDeclined(t *testing.T) {
+	const src = "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cfg := &config{
+		dir:     dir,
+		all:     true,
+		from:    "string",
+		to:      "[]byte",
+		confirm: confirmField,
+		stdin:   strings.NewReader("n\nn\n"),
+		stderr:  &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.processDir(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	// declining every change isn't reported as no match
+	stderr.Reset()
+	if err := cfg.reportCount(); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+
+	want := "modified 0 field(s), declined 2\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	test := []struct {
+		name    string
+		cfg     *config
+		wantErr string
+	}{
+		{
+			name:    "with file",
+			cfg:     &config{dir: ".", file: "foo.go", all: true},
+			wantErr: "-file or -dir cannot be used together. pick one",
+		},
+		{
+			name:    "with line",
+			cfg:     &config{dir: ".", line: "4"},
+			wantErr: "-line, -offset, -offset-range, -lsp-position and -only-lines cannot be used with -dir",
+		},
+		{
+			name:    "include vendor without dir",
+			cfg:     &config{file: "foo.go", all: true, includeVendor: true},
+			wantErr: "-include-vendor is requiring -dir",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			if err := ts.cfg.validate(); err == nil || err.Error() != ts.wantErr {
+				t.Errorf("got error %v, want %q", err, ts.wantErr)
+			}
+		})
+	}
+}
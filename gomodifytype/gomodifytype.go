@@ -0,0 +1,3153 @@
+// Package gomodifytype modifies the types of Go struct fields. It's the
+// implementation of the gomodifytype command, and can be used to run the
+// rewrite from other programs with a Rewriter.
+package gomodifytype
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// structType contains a structType node and it's name. It's a convenient
+// helper type, because *ast.StructType doesn't contain the name of the struct
+type structType struct {
+	name string
+	node *ast.StructType
+	// tagged is true if any field of the struct has a tag
+	tagged bool
+	// table is true if it's the element type of a slice literal, i.e. the
+	// cases of a table driven test
+	table bool
+}
+
+// lineRange is a range of lines, pos is the position of the node spanning
+// them, if any.
+type lineRange struct {
+	pos        token.Pos
+	start, end int
+}
+
+// typePair is a -from type and the -to type it's changed to.
+type typePair struct {
+	from, to string
+}
+
+// stringList is a flag which can be passed several times, accumulating its
+// values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// newStringList defines a repeatable string flag.
+func newStringList(name, usage string) *stringList {
+	l := new(stringList)
+	flag.Var(l, name, usage)
+	return l
+}
+
+// first returns the first value of the list, if any.
+func (l stringList) first() string {
+	if len(l) == 0 {
+		return ""
+	}
+	return l[0]
+}
+
+// Scopes define which kind of declarations are rewritten.
+const (
+	// scopeFields rewrites the types of struct fields
+	scopeFields = "fields"
+	// scopeTypeParams rewrites the constraints of type parameters
+	scopeTypeParams = "typeparams"
+	// scopeTypeDecl rewrites the types of type declarations and aliases
+	scopeTypeDecl = "typedecl"
+	// scopeFuncs rewrites the parameter and result types of function
+	// declarations
+	scopeFuncs = "funcs"
+	// scopeVars rewrites the types of var and const declarations
+	scopeVars = "vars"
+)
+
+type config struct {
+	file       string
+	dir        string
+	write      bool
+	structName string
+	fieldName  string
+	path       string
+	line       string
+	start      int
+	end        int
+	all        bool
+	from       string
+	to         string
+	froms      []string
+	tos        []string
+	pairs      []typePair // built by parseTargets
+	typeArg    string
+	reverse    bool
+	importPath string
+	ruleSrc    string
+	rule       *rule
+	scope      string
+
+	deprecatedFile string
+	deprecated     []deprecatedType
+
+	// synced are the field types of the -sync-from struct, by field name
+	syncFrom string
+	synced   map[string]string
+
+	offset      string
+	offsetRange string
+	startOffset int
+	endOffset   int
+	lspPosition string
+	structIndex int
+	onlyLines   string
+	onlyLineSet map[int]bool
+	lineRanges  []lineRange
+
+	// structRanges are the ranges of the structs, or fields, matching
+	// -struct
+	structRanges []lineRange
+
+	// allMatches processes all the structs with the -struct name, instead
+	// of rejecting the ambiguous name
+	allMatches bool
+
+	// multipleSelectors intersects the passed selectors, instead of
+	// rejecting their combinations
+	multipleSelectors bool
+
+	excludeLine  string
+	excludeStart int
+	excludeEnd   int
+
+	blameAuthor string
+	blameLines  map[int]bool
+
+	skipUnexportedFields bool
+	onlyUntagged         bool
+	requireTags          bool
+	recurseStructs       bool
+	maxDepth             int
+	noDeref              bool
+	onlyPointers         bool
+	onlyNonPointers      bool
+	collapsePointers     bool
+	deep                 bool
+	mapKey               bool
+	mapValue             bool
+	skipDirective        string
+	fieldStride          int
+	limitPerStruct       int
+	fromExported         bool
+	testTables           bool
+	includeVendor        bool
+	fieldCommentRegex    string
+	fieldCommentRe       *regexp.Regexp
+
+	semantic             bool
+	abortOnAmbiguousFrom bool
+	fromUnderlying       string
+	fromSize             int64
+	retypeConstraint     string
+	constraintFrom       string
+	constraintTo         string
+
+	traceStages     bool
+	reportDiffStats bool
+	affectedTypes   bool
+	typeGraph       bool
+	summary         bool
+	quiet           bool
+	positions       bool
+	list            bool
+	warnAPIBreak    bool
+	validateOnly    bool
+	jsonOutput      bool
+	outputFormat    string
+	diff            bool
+	color           string
+	printSchema     bool
+	confirm         string
+	stdinFilename   string
+	pairsDir        string
+	stdin           io.Reader
+	stdout          io.Writer
+	stderr          io.Writer
+
+	ensureFinalNewline bool
+	ensureParses       bool
+	normalizeTo        bool
+	simplify           bool
+	formatOnly         bool
+	markDone           string
+	migrationFile      string
+	skipIfMarked       bool
+	tidyImports        bool
+	printImports       bool
+
+	// src is the original content of the file
+	src     []byte
+	fileSet *token.FileSet
+	visited map[*ast.Field]bool
+	owners  map[*ast.Field]*structType
+	changes []change
+
+	// structChanges are the numbers of changed field names by struct, for
+	// -limit-per-struct
+	structChanges map[*ast.StructType]int
+
+	// depth is the number of nested structs rewriteNested is in
+	depth int
+
+	// fromExprs are the parsed -from types, nil if they're not a valid
+	// expression
+	fromExprs map[string]ast.Expr
+
+	// targets are the parsed types fields are changed to
+	targets map[string]ast.Expr
+
+	// answers reads the -confirm answers from stdin
+	answers *bufio.Reader
+
+	// declined is the number of changes declined with -confirm field
+	declined int
+
+	// stats are the line counts of the diffs of the processed files, for
+	// -report-diff-stats
+	stats diffStats
+
+	// replacedTypes are the replaced type expressions, the comments inside
+	// them are dropped along with them
+	replacedTypes []ast.Expr
+
+	// importChanges are the imports added and removed by the rewrite, for
+	// -print-imports
+	importChanges []importChange
+
+	// splitComments are the comments of split groups, inserted next to the
+	// split off fields once the file is formatted
+	splitComments []splitComments
+
+	// splitOrigins are the origins of the split off fields in the original
+	// file, by field
+	splitOrigins map[*ast.Field]splitOrigin
+
+	// populated in semantic mode only
+	parsed         *ast.File
+	pkg            *types.Package
+	info           *types.Info
+	checkedTargets map[string]bool
+}
+
+// Run runs the gomodifytype command with the given command line arguments,
+// without the program name.
+func Run(args []string) error {
+	cfg, err := parseConfig(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	if cfg.printSchema {
+		return writeChangeSchema(os.Stdout)
+	}
+
+	// listing the structs needs nothing but the file
+	if cfg.list {
+		return cfg.listStructs(os.Stdout)
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.validateOnly {
+		count, err := cfg.validateSelection()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("selection is valid, %d field(s) selected\n", count)
+		return nil
+	}
+
+	if cfg.dir != "" {
+		err = cfg.processDir(os.Stdout)
+	} else {
+		var out string
+		out, err = cfg.process()
+		if err == nil {
+			err = cfg.emitPair(filepath.Base(cfg.filename()), out)
+		}
+		if err == nil {
+			cfg.printFile(os.Stdout, out)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.reportDiffStats {
+		cfg.printDiffStats()
+	}
+
+	switch {
+	case cfg.typeGraph:
+		err = writeTypeGraph(os.Stdout, cfg.changes)
+	case cfg.positions:
+		err = writePositions(os.Stdout, cfg.changes)
+	case cfg.jsonOutput:
+		err = writeChangesJSON(os.Stdout, cfg.changes)
+	case cfg.outputFormat == outputSARIF:
+		err = writeChangesSARIF(os.Stdout, cfg.changes)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.migrationFile != "" {
+		if err := cfg.emitMigration(); err != nil {
+			return err
+		}
+	}
+
+	// the summary comes last, after the changes are printed
+	if cfg.summary {
+		writeSummary(os.Stderr, cfg.changes)
+	}
+
+	// nothing is expected to change when the file is only formatted, or
+	// when the types are only reported
+	if cfg.formatOnly || cfg.typeGraph || cfg.positions {
+		return nil
+	}
+	return cfg.reportCount()
+}
+
+// ErrNoMatch is returned by Run if no type is changed. The command
+// exits with status 3 then, so scripts can tell it from other errors.
+var ErrNoMatch = errors.New("no type is changed")
+
+// reportCount prints the number of changed types to stderr, unless -quiet is
+// passed. It returns ErrNoMatch if there are none, unless the changes were
+// declined with -confirm.
+func (c *config) reportCount() error {
+	if !c.quiet {
+		stderr := c.stderr
+		if stderr == nil {
+			stderr = os.Stderr
+		}
+		_, _ = fmt.Fprintf(stderr, "modified %d %s", len(c.changes), c.changedNoun())
+		if c.declined > 0 {
+			_, _ = fmt.Fprintf(stderr, ", declined %d", c.declined)
+		}
+		_, _ = fmt.Fprintln(stderr)
+	}
+
+	if len(c.changes) == 0 && c.declined == 0 {
+		return ErrNoMatch
+	}
+	return nil
+}
+
+// changedNoun returns what is counted by reportCount in the -scope.
+func (c *config) changedNoun() string {
+	switch c.scope {
+	case scopeTypeParams:
+		return "type parameter(s)"
+	case scopeTypeDecl:
+		return "type declaration(s)"
+	case scopeFuncs:
+		return "parameter(s) or result(s)"
+	case scopeVars:
+		return "var or const declaration(s)"
+	}
+	return "field(s)"
+}
+
+// printDiffStats prints the line counts of the diffs of all processed files
+// to stderr.
+func (c *config) printDiffStats() {
+	stderr := c.stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	_, _ = fmt.Fprintln(stderr, c.stats)
+}
+
+// printFile prints the rewritten file, or its diff with -diff. Nothing is
+// printed if the changes are reported in a structured format instead.
+func (c *config) printFile(w io.Writer, out string) {
+	// the change records are already streamed during the rewrite with
+	// jsonl, the others are printed for all files at once
+	if c.jsonOutput || c.outputFormat == outputSARIF || c.outputFormat == outputJSONL || c.typeGraph || c.positions {
+		return
+	}
+
+	if c.diff {
+		diff := unifiedDiff(c.filename(), diffLines(splitLines(string(c.src)), splitLines(out)))
+		if c.useColor(w) {
+			diff = colorizeDiff(diff)
+		}
+		_, _ = fmt.Fprint(w, diff)
+		return
+	}
+
+	if !c.write {
+		_, _ = fmt.Fprint(w, out)
+	}
+}
+
+// process runs the parse, select, rewrite and format stages for the
+// configured file and returns the formatted result.
+func (c *config) process() (string, error) {
+	t := time.Now()
+	node, err := c.parse()
+	if err != nil {
+		return "", err
+	}
+	c.trace("parse", t)
+
+	if c.skipIfMarked && hasMarker(node.(*ast.File), c.markDone) {
+		stderr := c.stderr
+		if stderr == nil {
+			stderr = os.Stderr
+		}
+		_, _ = fmt.Fprintf(stderr, "%s: skipped, the file is already marked with %q\n", c.filename(), c.markDone)
+		return string(c.src), nil
+	}
+
+	if c.formatOnly {
+		return c.formatFile(node)
+	}
+
+	t = time.Now()
+	start, end, err := c.findSelection(node)
+	if err != nil {
+		var ambiguous *ambiguousStructError
+		if errors.As(err, &ambiguous) {
+			return "", err
+		}
+		return "", &selectionError{err: err}
+	}
+	c.trace("select", t)
+
+	t = time.Now()
+	rewrittenNode, err := c.rewrite(node, start, end)
+	if err != nil {
+		return "", err
+	}
+
+	if len(c.changes) != 0 {
+		c.addImports(rewrittenNode.(*ast.File))
+
+		if c.tidyImports {
+			c.removeUnusedImports(rewrittenNode.(*ast.File))
+		}
+
+		if c.printImports {
+			c.printImportChanges()
+		}
+	}
+
+	if c.simplify {
+		simplify(rewrittenNode)
+	}
+	c.trace("rewrite", t)
+
+	t = time.Now()
+	out, err := c.format(rewrittenNode)
+	if err != nil {
+		return "", err
+	}
+	c.trace("format", t)
+
+	if c.reportDiffStats {
+		c.stats.add(diffLines(splitLines(string(c.src)), splitLines(out)))
+	}
+
+	if c.affectedTypes {
+		c.printAffectedTypes()
+	}
+
+	if c.warnAPIBreak {
+		c.printAPIBreaks()
+	}
+
+	return out, nil
+}
+
+// formatFile formats the parsed file without rewriting it, for -format-only.
+func (c *config) formatFile(node ast.Node) (string, error) {
+	if c.simplify {
+		simplify(node)
+	}
+
+	t := time.Now()
+	out, err := c.format(node)
+	if err != nil {
+		return "", err
+	}
+	c.trace("format", t)
+	return out, nil
+}
+
+// validateSelection parses the file and resolves the selection without
+// rewriting anything. It returns the number of selected fields, or type
+// declarations with -scope typedecl.
+func (c *config) validateSelection() (int, error) {
+	node, err := c.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, err := c.findSelection(node)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.TypeSpec); ok && c.scope == scopeTypeDecl {
+			line := c.fileSet.Position(spec.Pos()).Line
+			if start <= line && line <= end {
+				count++
+			}
+			return true
+		}
+
+		// specs without an explicit type, i.e: var n = 1, are left alone
+		if spec, ok := n.(*ast.ValueSpec); ok && c.scope == scopeVars && spec.Type != nil {
+			line := c.fileSet.Position(spec.Pos()).Line
+			if start <= line && line <= end {
+				count++
+			}
+			return true
+		}
+
+		for _, fields := range c.scopeFields(n) {
+			for i, f := range fields.List {
+				if c.inSelection(f, start, end) && c.inStride(i) {
+					count++
+				}
+			}
+		}
+		return true
+	})
+
+	return count, nil
+}
+
+// warnf prints a warning about the given position to stderr.
+func (c *config) warnf(pos token.Pos, format string, args ...interface{}) {
+	w := c.stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	_, _ = fmt.Fprintf(w, "%s: warning: %s\n", c.fileSet.Position(pos), fmt.Sprintf(format, args...))
+}
+
+// trace prints the wall time elapsed since the given stage started if
+// tracing is enabled.
+func (c *config) trace(stage string, since time.Time) {
+	if !c.traceStages {
+		return
+	}
+	_, _ = fmt.Fprintf(c.stderr, "trace: %s took %s\n", stage, time.Since(since))
+}
+
+func parseConfig(args []string) (*config, error) {
+	var (
+		flagFile    = flag.String("file", "", "Filename to be parsed, - reads the source from stdin")
+		flagDir     = flag.String("dir", "", "Directory to be processed recursively, testdata and vendor directories are skipped, see -include-vendor")
+		flagWrite   = flag.Bool("w", false, "Write result to source file instead of stdout")
+		flagLine    = flag.String("line", "", "Line number of the field or a range of line, several can be separated by semicolons. i.e: 4 or 4,8 or 4,6;12")
+		flagStruct  = flag.String("struct", "", "Struct name to be processed, or a comma separated list of names or glob patterns. i.e: User,Account or User*")
+		flagField   = flag.String("field", "", "Field name to be processed, or a comma separated list of names or glob patterns. i.e: ID,Name or Created*")
+		flagPath    = flag.String("path", "", "Dotted path of a nested field to be processed. i.e: Outer.Inner.Field")
+		flagAll     = flag.Bool("all", false, "Select all structs to be processed")
+		flagFrom    = newStringList("from", "From type, can be passed several times along with -to")
+		flagTo      = newStringList("to", "To type, $NAME and $FROM expand to the field name and its current type. i.e: internal.Typed$NAME")
+		flagTypeArg = flag.String("type-arg", "", "Type argument of generic instantiations to be changed to -to instead of matching -from. i.e: Old for pkg.Container[Old]")
+		flagReverse = flag.Bool("reverse", false, "Swap -from and -to, i.e: to undo a previous run")
+		flagScope   = flag.String("scope", scopeFields, "Declarations to be processed: fields, typeparams, typedecl, funcs or vars")
+		flagFuncs   = flag.Bool("funcs", false, "Rewrite the parameter and result types of function declarations, a shorthand for -scope funcs")
+		flagVars    = flag.Bool("vars", false, "Rewrite the types of var and const declarations, a shorthand for -scope vars")
+		flagRule    = flag.String("rule", "", `Rule selecting fields and their new type, i.e: field.Type == "int" && hasTag(field, "money") => "decimal.Decimal"`)
+
+		flagImportPath      = flag.String("import-path", "", "Import path of the package qualifying -to, if it's not the package name. i.e: github.com/x/pb for pb.Msg")
+		flagDeprecatedTypes = flag.String("deprecated-types", "", "File listing deprecated types, one per line, optionally followed by => and their replacement. Fields without a replacement are reported")
+		flagSyncFrom        = flag.String("sync-from", "", "Reference file declaring the -struct. Fields are changed to the type of the reference field with the same name")
+
+		flagOffset      = flag.String("offset", "", "Byte offset of the field to be processed, as known by editors. i.e: 52")
+		flagOffsetRange = flag.String("offset-range", "", "Byte offset range of the fields to be processed. i.e: 40,120")
+		flagOnlyLines   = flag.String("only-lines", "", "Comma separated list of the lines of the fields to be processed. i.e: 4,9,15")
+		flagStructIndex = flag.Int("struct-index", 0, "One based index of the struct to be processed, in source order")
+		flagBlameAuthor = flag.String("blame-author", "", "Only process fields on lines last modified by the given git author")
+		flagExcludeLine = flag.String("exclude-line", "", "Line number or range of lines of fields to be spared within the selection. i.e: 10 or 10,12")
+		flagLSPPosition = flag.String("lsp-position", "", "Zero based line:character position of the field to be processed. i.e: 4:1")
+
+		flagAllMatches             = flag.Bool("all-matches", false, "Process all the structs with the -struct name, i.e. declared in different functions, instead of rejecting the ambiguous name")
+		flagAllowMultipleSelectors = flag.Bool("allow-multiple-selectors", false, "Allow combining selectors, i.e: -line and -struct. Only the fields selected by all of them are processed")
+
+		flagSkipUnexportedFields = flag.Bool("skip-unexported", false, "Skip unexported fields")
+		flagOnlyUntagged         = flag.Bool("only-untagged", false, "Only process fields without a struct tag")
+		flagRequireTags          = flag.Bool("require-tags", false, "Skip structs without any tagged field")
+		flagRecurseStructs       = flag.Bool("recurse-structs", false, "Process all fields of inline structs nested in selected fields")
+		flagMaxDepth             = flag.Int("max-depth", 0, "Maximum number of nested structs -recurse-structs descends into, unlimited by default")
+		flagNoDeref              = flag.Bool("no-deref", false, "Don't select structs through pointers and slices with -struct")
+		flagOnlyPointers         = flag.Bool("only-pointers", false, "Only process pointer fields, -from is matched against the pointee")
+		flagOnlyNonPointers      = flag.Bool("only-non-pointers", false, "Only process non-pointer fields")
+		flagCollapsePointers     = flag.Bool("collapse-pointers", false, "Replace pointers to pointers, i.e: **T, with a single pointer")
+		flagMapKey               = flag.Bool("map-key", false, "Only match -from against the key type of map fields")
+		flagMapValue             = flag.Bool("map-value", false, "Only match -from against the value type of map fields")
+		flagDeep                 = flag.Bool("deep", false, "Match -from against pointer, slice, array and map element types and type arguments too, i.e: *string becomes *[]byte")
+		flagFieldCommentRegex    = flag.String("field-comment-regex", "", "Only process fields with a doc or line comment matching the regular expression")
+		flagFromExported         = flag.Bool("from-exported", false, "Only process fields whose type is an exported name, i.e: Foo or pkg.Foo")
+		flagIncludeVendor        = flag.Bool("include-vendor", false, "Process the vendor directories with -dir, i.e: to intentionally edit vendored code")
+		flagTestTables           = flag.Bool("test-tables", false, "Only process the fields of table driven test cases, i.e: []struct{ in, want T }{...} in _test.go files")
+		flagLimitPerStruct       = flag.Int("limit-per-struct", 0, "Change at most N fields of each struct, in source order, i.e. for staged rollouts")
+		flagFieldStride          = flag.Int("field-stride", 0, "Only process every Nth field of a struct, starting with the first one")
+		flagSkipDirective        = flag.String("skip-directive", defaultSkipDirective, "Skip fields with a line comment starting with this directive")
+
+		flagSemantic             = flag.Bool("semantic", false, "Type check the file to resolve field types")
+		flagAbortOnAmbiguousFrom = flag.Bool("abort-on-ambiguous-from", false, "Abort if -from resolves to more than one type (requires -semantic)")
+		flagFromSize             = flag.Int64("from-size", 0, "Match types with the given size in bytes instead of -from (requires -semantic)")
+		flagRetypeConstraint     = flag.String("retype-constraint", "", "Retype the constraints of type parameters used by the selected fields, i.e: Old=New rewrites ~Old to ~New (requires -semantic)")
+		flagFromUnderlying       = flag.String("from-underlying", "", "Match named types with the given underlying type instead of -from (requires -semantic)")
+
+		flagEnsureFinalNewline = flag.Bool("ensure-final-newline", true, "Make sure the output ends with a newline")
+		flagFormatOnly         = flag.Bool("format-only", false, "Only gofmt the file, without -from and -to. It's written back with -w")
+		flagSimplify           = flag.Bool("simplify", false, "Simplify the rewritten file like gofmt -s")
+		flagNormalizeTo        = flag.Bool("normalize-to", false, "Canonicalize -to in gofmt style before inserting it. i.e: [ ]byte becomes []byte")
+		flagEnsureParses       = flag.Bool("ensure-parses", false, "Fail if the rewritten file doesn't parse (default true with -w)")
+		flagMarkDone           = flag.String("mark-done", "", "Marker comment added after the package clause of changed files, i.e: // migrated:v2")
+		flagEmitMigration      = flag.String("emit-migration", "", "File the stubs of functions migrating the changed structs are written to, i.e: migrations.go")
+		flagTidyImports        = flag.Bool("tidy-imports", false, "Remove the imports of -from packages which aren't used anymore after the rewrite")
+		flagPrintImports       = flag.Bool("print-imports", false, "Print the added and removed imports to stderr, i.e: + \"time\"")
+		flagSkipIfMarked       = flag.Bool("skip-if-marked", false, "Skip files which already have the -mark-done comment")
+
+		flagTrace           = flag.Bool("trace", false, "Print the time spent in each stage to stderr")
+		flagReportDiffStats = flag.Bool("report-diff-stats", false, "Print a summary of changed lines to stderr")
+		flagAffectedTypes   = flag.Bool("affected-types", false, "Print the types which took part in a change to stderr")
+		flagList            = flag.Bool("list", false, "Print the structs of the file with the types of their fields, nothing is rewritten")
+		flagPositions       = flag.Bool("positions", false, "Print the file:start-end byte offset spans of the changed fields in the original file instead of the rewritten file")
+		flagQuiet           = flag.Bool("quiet", false, "Don't print the number of modified fields to stderr")
+		flagSummary         = flag.Bool("summary", false, "Print a one line summary of the changes to stderr")
+		flagTypeGraph       = flag.Bool("type-graph", false, "Print the fields of each struct matching -from instead of the rewritten file, nothing is written")
+		flagColor           = flag.String("color", colorAuto, "Colorize the -diff output: auto, if stdout is a terminal, always or never")
+		flagEmitPairs       = flag.String("emit-pairs", "", "Directory the original and rewritten content of each changed file is written to, as name.before and name.after")
+		flagDiff            = flag.Bool("diff", false, "Print a unified diff of the changes instead of the rewritten file")
+		flagJSON            = flag.Bool("json", false, "Print the changes as JSON records instead of the rewritten file")
+		flagOutputFormat    = flag.String("output-format", outputText, "Output format: text, json, jsonl, which streams one JSON change record per line, or sarif")
+		flagPrintSchema     = flag.Bool("print-schema", false, "Print the JSON schema of the -json change records")
+		flagValidateOnly    = flag.Bool("validate-only", false, "Only check the flags and the selection, without rewriting the file")
+		flagStdinFilename   = flag.String("stdin-filename", defaultStdinFilename, "Filename used in messages about the source read from stdin")
+		flagConfirm         = flag.String("confirm", "", "Ask before writing the file with -w, or before each field change: file or field")
+		flagWarnAPIBreak    = flag.Bool("warn-on-api-break", false, "Warn when an exported field of an exported struct is retyped")
+	)
+
+	// this fails if there are flags re-defined with the same name.
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := setFlagsFromEnv(); err != nil {
+		return nil, err
+	}
+
+	if flag.NFlag() == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		return nil, flag.ErrHelp
+	}
+
+	cfg := &config{
+		file:                 *flagFile,
+		dir:                  *flagDir,
+		line:                 *flagLine,
+		structName:           *flagStruct,
+		fieldName:            *flagField,
+		path:                 *flagPath,
+		all:                  *flagAll,
+		offsetRange:          *flagOffsetRange,
+		lspPosition:          *flagLSPPosition,
+		multipleSelectors:    *flagAllowMultipleSelectors,
+		allMatches:           *flagAllMatches,
+		offset:               *flagOffset,
+		structIndex:          *flagStructIndex,
+		onlyLines:            *flagOnlyLines,
+		excludeLine:          *flagExcludeLine,
+		blameAuthor:          *flagBlameAuthor,
+		write:                *flagWrite,
+		from:                 flagFrom.first(),
+		to:                   flagTo.first(),
+		froms:                *flagFrom,
+		tos:                  *flagTo,
+		typeArg:              *flagTypeArg,
+		reverse:              *flagReverse,
+		importPath:           *flagImportPath,
+		ruleSrc:              *flagRule,
+		deprecatedFile:       *flagDeprecatedTypes,
+		syncFrom:             *flagSyncFrom,
+		scope:                *flagScope,
+		skipUnexportedFields: *flagSkipUnexportedFields,
+		onlyUntagged:         *flagOnlyUntagged,
+		requireTags:          *flagRequireTags,
+		recurseStructs:       *flagRecurseStructs,
+		maxDepth:             *flagMaxDepth,
+		noDeref:              *flagNoDeref,
+		onlyPointers:         *flagOnlyPointers,
+		onlyNonPointers:      *flagOnlyNonPointers,
+		collapsePointers:     *flagCollapsePointers,
+		deep:                 *flagDeep,
+		mapKey:               *flagMapKey,
+		mapValue:             *flagMapValue,
+		skipDirective:        *flagSkipDirective,
+		fieldStride:          *flagFieldStride,
+		limitPerStruct:       *flagLimitPerStruct,
+		fromExported:         *flagFromExported,
+		testTables:           *flagTestTables,
+		includeVendor:        *flagIncludeVendor,
+		fieldCommentRegex:    *flagFieldCommentRegex,
+		semantic:             *flagSemantic,
+		abortOnAmbiguousFrom: *flagAbortOnAmbiguousFrom,
+		fromUnderlying:       *flagFromUnderlying,
+		fromSize:             *flagFromSize,
+		retypeConstraint:     *flagRetypeConstraint,
+		traceStages:          *flagTrace,
+		reportDiffStats:      *flagReportDiffStats,
+		affectedTypes:        *flagAffectedTypes,
+		typeGraph:            *flagTypeGraph,
+		summary:              *flagSummary,
+		quiet:                *flagQuiet,
+		positions:            *flagPositions,
+		list:                 *flagList,
+		warnAPIBreak:         *flagWarnAPIBreak,
+		validateOnly:         *flagValidateOnly,
+		jsonOutput:           *flagJSON,
+		outputFormat:         *flagOutputFormat,
+		diff:                 *flagDiff,
+		pairsDir:             *flagEmitPairs,
+		color:                *flagColor,
+		confirm:              *flagConfirm,
+		stdinFilename:        *flagStdinFilename,
+		printSchema:          *flagPrintSchema,
+		ensureFinalNewline:   *flagEnsureFinalNewline,
+		ensureParses:         *flagEnsureParses,
+		normalizeTo:          *flagNormalizeTo,
+		simplify:             *flagSimplify,
+		formatOnly:           *flagFormatOnly,
+		markDone:             *flagMarkDone,
+		migrationFile:        *flagEmitMigration,
+		skipIfMarked:         *flagSkipIfMarked,
+		tidyImports:          *flagTidyImports,
+		printImports:         *flagPrintImports,
+		stdin:                os.Stdin,
+		stdout:               os.Stdout,
+		stderr:               os.Stderr,
+	}
+
+	// never overwrite a file with something that doesn't parse, unless
+	// asked explicitly
+	ensureParsesSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "ensure-parses" {
+			ensureParsesSet = true
+		}
+	})
+	if !ensureParsesSet {
+		cfg.ensureParses = cfg.write
+	}
+
+	if *flagFuncs {
+		if cfg.scope != scopeFields {
+			return nil, errors.New("-funcs cannot be used together with -scope")
+		}
+		cfg.scope = scopeFuncs
+	}
+
+	if *flagVars {
+		if cfg.scope != scopeFields {
+			return nil, errors.New("-vars cannot be used together with -scope or -funcs")
+		}
+		cfg.scope = scopeVars
+	}
+
+	return cfg, nil
+}
+
+const (
+	// stdinFile is the -file value reading the source from stdin
+	stdinFile = "-"
+	// defaultStdinFilename is the file name used in positions of the
+	// source read from stdin
+	defaultStdinFilename = "stdin.go"
+)
+
+// defaultSkipDirective is the line comment directive fields are skipped
+// with by default.
+const defaultSkipDirective = "gomodifytype:skip"
+
+// envPrefix is the prefix of the environment variables flags can be set
+// with, i.e: GOMODIFYTYPE_FROM for -from.
+const envPrefix = "GOMODIFYTYPE_"
+
+// setFlagsFromEnv sets the flags which aren't passed on the command line from
+// their environment variables, if any. Flags passed on the command line take
+// precedence.
+func setFlagsFromEnv() error {
+	passed := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if passed[f.Name] || err != nil {
+			return
+		}
+
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		if setErr := flag.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %s", value, name, setErr)
+		}
+	})
+	return err
+}
+
+// filename returns the name of the processed file as used in messages, that's
+// -stdin-filename if the source is read from stdin.
+func (c *config) filename() string {
+	if c.file != stdinFile {
+		return c.file
+	}
+	if c.stdinFilename == "" {
+		return defaultStdinFilename
+	}
+	return c.stdinFilename
+}
+
+func (c *config) parse() (ast.Node, error) {
+	var src []byte
+	var err error
+	if c.file == stdinFile {
+		var r io.Reader = c.stdin
+		if r == nil {
+			r = os.Stdin
+		}
+		src, err = ioutil.ReadAll(r)
+	} else {
+		src, err = ioutil.ReadFile(c.file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.src = src
+
+	c.fileSet = token.NewFileSet()
+	file, err := parser.ParseFile(c.fileSet, c.filename(), src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.semantic {
+		c.typeCheck(file)
+	}
+
+	if c.blameAuthor != "" {
+		c.blameLines, err = c.authorLines()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// selector is a selection of fields by one of the selection flags.
+type selector struct {
+	passed bool
+	find   func(node ast.Node) (int, int, error)
+}
+
+// selectors returns the selectors of all selection flags, passed or not.
+func (c *config) selectors() []selector {
+	return []selector{
+		{passed: c.line != "", find: c.lineSelection},
+		{passed: c.structName != "", find: c.structSelection},
+		{passed: c.structIndex != 0, find: c.structIndexSelection},
+		{passed: c.path != "", find: c.pathSelection},
+		{passed: c.offset != "", find: c.offsetSelection},
+		{passed: c.offsetRange != "", find: c.offsetRangeSelection},
+		{passed: c.lspPosition != "", find: c.lspPositionSelection},
+		{passed: c.onlyLines != "", find: c.onlyLinesSelection},
+		{passed: c.all, find: c.allSelection},
+	}
+}
+
+// findSelection returns the start and end position of the fields that are
+// suspect to change. The lines selected by each of the passed selectors are
+// intersected, validate makes sure there is only one of them, or -all,
+// unless -allow-multiple-selectors is passed.
+func (c *config) findSelection(node ast.Node) (int, int, error) {
+	start, end, found := 0, 0, false
+	for _, s := range c.selectors() {
+		if !s.passed {
+			continue
+		}
+
+		sStart, sEnd, err := s.find(node)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if !found {
+			start, end, found = sStart, sEnd, true
+			continue
+		}
+		if sStart > start {
+			start = sStart
+		}
+		if sEnd < end {
+			end = sEnd
+		}
+	}
+
+	if !found {
+		return 0, 0, errors.New("-line, -struct, -struct-index, -path, -offset, -offset-range, -lsp-position, -only-lines or -all is not passed")
+	}
+
+	if start > end {
+		return 0, 0, errors.New("the selections don't have any line in common")
+	}
+	return start, end, nil
+}
+
+// collectStructs collects and maps structType nodes to their positions. If
+// unwrap is false, pointers and slices of structs are not collected.
+func collectStructs(node ast.Node, unwrap bool) map[token.Pos]*structType {
+	structs := make(map[token.Pos]*structType)
+
+	// literals maps composite literals to the names of the variables they
+	// are assigned to, i.e. "x" in `x := struct{ N int }{N: 1}`
+	literals := make(map[*ast.CompositeLit]string)
+	nameLiterals := func(names []ast.Expr, values []ast.Expr) {
+		if len(names) != len(values) {
+			return
+		}
+		for i, v := range values {
+			lit, ok := v.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			if ident, ok := names[i].(*ast.Ident); ok && ident.Name != "_" {
+				literals[lit] = ident.Name
+			}
+		}
+	}
+
+	collectStructs := func(n ast.Node) bool {
+		var t ast.Expr
+		var structName string
+		table := false
+
+		switch x := n.(type) {
+		case *ast.TypeSpec:
+			if x.Type == nil {
+				return true
+
+			}
+
+			structName = x.Name.Name
+			t = x.Type
+		case *ast.CompositeLit:
+			structName = literals[x]
+			t = x.Type
+			if slice, ok := t.(*ast.ArrayType); ok {
+				_, table = slice.Elt.(*ast.StructType)
+			}
+		case *ast.ValueSpec:
+			structName = x.Names[0].Name
+			t = x.Type
+			if t == nil {
+				names := make([]ast.Expr, len(x.Names))
+				for i, name := range x.Names {
+					names[i] = name
+				}
+				nameLiterals(names, x.Values)
+			}
+		case *ast.AssignStmt:
+			nameLiterals(x.Lhs, x.Rhs)
+		case *ast.Field:
+			// this case also catches struct fields and the structName
+			// therefore might contain the field name (which is wrong)
+			// because `x.Type` in this case is not a *ast.StructType.
+			//
+			// We're OK with it, because, in our case *ast.Field represents
+			// a parameter declaration, i.e:
+			//
+			//   func test(arg struct {
+			//   	Field int
+			//   }) {
+			//   }
+			//
+			// and hence the struct name will be `arg`.
+			if len(x.Names) != 0 {
+				structName = x.Names[0].Name
+			}
+			t = x.Type
+		}
+
+		// if expression is in form "*T" or "[]T", dereference to check if "T"
+		// contains a struct expression
+		if unwrap {
+			t = deref(t)
+		}
+
+		x, ok := t.(*ast.StructType)
+		if !ok {
+			return true
+		}
+
+		tagged := false
+		for _, f := range x.Fields.List {
+			if f.Tag != nil {
+				tagged = true
+				break
+			}
+		}
+
+		structs[x.Pos()] = &structType{
+			name:   structName,
+			node:   x,
+			tagged: tagged,
+			table:  table,
+		}
+		return true
+	}
+
+	ast.Inspect(node, collectStructs)
+	return structs
+}
+
+func (c *config) format(file ast.Node) (string, error) {
+	var buf bytes.Buffer
+	err := format.Node(&buf, c.fileSet, file)
+	if err != nil {
+		return "", err
+	}
+
+	if len(c.splitComments) != 0 {
+		commented, err := insertSplitComments(buf.Bytes(), file, c.splitComments)
+		if err != nil {
+			return "", err
+		}
+		buf.Reset()
+		buf.Write(commented)
+	}
+
+	if c.markDone != "" && len(c.changes) != 0 && !hasMarker(file.(*ast.File), c.markDone) {
+		marked, err := insertMarker(buf.Bytes(), c.markDone)
+		if err != nil {
+			return "", err
+		}
+		buf.Reset()
+		buf.Write(marked)
+	}
+
+	if c.ensureFinalNewline && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	if c.ensureParses {
+		if _, err := format.Source(buf.Bytes()); err != nil {
+			return "", fmt.Errorf("rewritten file doesn't parse: %s", err)
+		}
+	}
+
+	if c.write && c.confirm == confirmFile && !c.ask("write %d change(s) to %s?", len(c.changes), c.file) {
+		return buf.String(), nil
+	}
+
+	if c.write {
+		err = ioutil.WriteFile(c.file, buf.Bytes(), 0)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// lineSelection parses the semicolon separated list of lines or ranges of
+// lines, i.e: 4,6;12;20,22, and selects the range they span. The fields are
+// additionally filtered by the ranges in rewrite.
+func (c *config) lineSelection(_ ast.Node) (int, int, error) {
+	c.lineRanges = nil
+
+	start, end := 0, 0
+	for _, part := range strings.Split(c.line, ";") {
+		rangeStart, rangeEnd, err := parseLineRange(strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("wrong line range %q: %s", part, err)
+		}
+		c.lineRanges = append(c.lineRanges, lineRange{start: rangeStart, end: rangeEnd})
+
+		if start == 0 || rangeStart < start {
+			start = rangeStart
+		}
+		if rangeEnd > end {
+			end = rangeEnd
+		}
+	}
+
+	return start, end, nil
+}
+
+// parseLineRange parses a single line or a range of lines, i.e: 4 or 4,8.
+func parseLineRange(s string) (int, int, error) {
+	var err error
+	parts := strings.Split(s, ",")
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := start
+	if len(parts) == 2 {
+		end, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if start > end {
+		return 0, 0, errors.New("wrong range. start line cannot be larger than end line")
+	}
+
+	return start, end, nil
+}
+
+// offsetRangeSelection parses the byte offset range and selects the lines it
+// spans. The fields are additionally filtered by their offset in rewrite.
+func (c *config) offsetRangeSelection(file ast.Node) (int, int, error) {
+	parts := strings.Split(c.offsetRange, ",")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("wrong offset range. expected start,end")
+	}
+
+	var err error
+	c.startOffset, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	c.endOffset, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if c.startOffset > c.endOffset {
+		return 0, 0, errors.New("wrong range. start offset cannot be larger than end offset")
+	}
+
+	tokFile := c.fileSet.File(file.Pos())
+	if c.startOffset < 0 || c.endOffset > tokFile.Size() {
+		return 0, 0, fmt.Errorf("wrong range. offsets must be between 0 and %d", tokFile.Size())
+	}
+
+	start := tokFile.Line(tokFile.Pos(c.startOffset))
+	end := tokFile.Line(tokFile.Pos(c.endOffset))
+
+	return start, end, nil
+}
+
+// offsetSelection selects the field enclosing a byte offset into the file,
+// i.e. the cursor position of an editor.
+func (c *config) offsetSelection(file ast.Node) (int, int, error) {
+	offset, err := strconv.Atoi(c.offset)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tokFile := c.fileSet.File(file.Pos())
+	if offset < 0 || offset > tokFile.Size() {
+		return 0, 0, fmt.Errorf("wrong offset. it must be between 0 and %d", tokFile.Size())
+	}
+
+	encField := c.enclosingField(file, tokFile.Pos(offset))
+	if encField == nil {
+		return 0, 0, fmt.Errorf("no struct field at offset %d", offset)
+	}
+
+	start := c.fileSet.Position(encField.Pos()).Line
+	end := c.fileSet.Position(encField.End()).Line
+
+	return start, end, nil
+}
+
+// onlyLinesSelection parses the list of discrete lines and selects the range
+// they span. The fields are additionally filtered by their line in rewrite.
+func (c *config) onlyLinesSelection(_ ast.Node) (int, int, error) {
+	c.onlyLineSet = make(map[int]bool)
+
+	start, end := 0, 0
+	for _, part := range strings.Split(c.onlyLines, ",") {
+		line, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, err
+		}
+		c.onlyLineSet[line] = true
+
+		if start == 0 || line < start {
+			start = line
+		}
+		if line > end {
+			end = line
+		}
+	}
+
+	return start, end, nil
+}
+
+// lspPositionSelection selects the field enclosing a zero based line:character
+// position, as used by the Language Server Protocol. The character is
+// counted in UTF-16 code units within the line, as LSP specifies.
+func (c *config) lspPositionSelection(file ast.Node) (int, int, error) {
+	parts := strings.Split(c.lspPosition, ":")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("wrong LSP position. expected line:character")
+	}
+
+	line, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	char, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// LSP positions are zero based, token positions are one based
+	tokFile := c.fileSet.File(file.Pos())
+	if line < 0 || line >= tokFile.LineCount() || char < 0 {
+		return 0, 0, fmt.Errorf("LSP position %s is outside of the file", c.lspPosition)
+	}
+
+	lineStart := tokFile.Offset(tokFile.LineStart(line + 1))
+	lineEnd := tokFile.Size()
+	if line+1 < tokFile.LineCount() {
+		lineEnd = tokFile.Offset(tokFile.LineStart(line+2)) - 1
+	}
+
+	offset, ok := utf16Offset(c.src[lineStart:lineEnd], char)
+	if !ok {
+		return 0, 0, fmt.Errorf("LSP position %s is past the end of its line", c.lspPosition)
+	}
+
+	encField := c.enclosingField(file, tokFile.Pos(lineStart+offset))
+	if encField == nil {
+		return 0, 0, fmt.Errorf("no struct field at LSP position %s", c.lspPosition)
+	}
+
+	start := c.fileSet.Position(encField.Pos()).Line
+	end := c.fileSet.Position(encField.End()).Line
+
+	return start, end, nil
+}
+
+// utf16Offset converts the character of an LSP position, which counts UTF-16
+// code units, to a byte offset in the line. It returns false if the character
+// is past the end of the line.
+func utf16Offset(line []byte, char int) (int, bool) {
+	units := 0
+	for offset, r := range string(line) {
+		if units >= char {
+			return offset, true
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(line), units >= char
+}
+
+// enclosingField returns the innermost struct field containing pos, or nil if
+// pos is not inside a struct field.
+func (c *config) enclosingField(file ast.Node, pos token.Pos) *ast.Field {
+	var encField *ast.Field
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+
+		if st, ok := n.(*ast.StructType); ok {
+			for _, f := range st.Fields.List {
+				if f.Pos() <= pos && pos < f.End() {
+					encField = f
+				}
+			}
+		}
+		return true
+	})
+	return encField
+}
+
+// structSelection selects the structs matching -struct, a comma separated
+// list of names or glob patterns, i.e: User,Account or User*, and returns
+// the range they span. The fields are additionally filtered by the ranges of
+// the matched structs in rewrite.
+func (c *config) structSelection(file ast.Node) (int, int, error) {
+	matched := c.matchStructs(file)
+	if len(matched) == 0 {
+		return 0, 0, errors.New("struct name does not exist")
+	}
+
+	if !c.allMatches {
+		if err := checkAmbiguousStructs(c.fileSet, matched); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	c.structRanges = nil
+	start, end := 0, 0
+	patterns := splitPatterns(c.fieldName)
+	found := make(map[string]bool)
+	for _, st := range matched {
+		structStart := c.fileSet.Position(st.node.Pos()).Line
+		structEnd := c.fileSet.Position(st.node.End()).Line
+
+		// if field name has been specified as well, only select the given
+		// field. Structs without it are skipped if several are matched
+		if c.fieldName != "" && len(matched) == 1 {
+			var err error
+			structStart, structEnd, err = c.fieldSelection(st.name, st.node)
+			if err != nil {
+				return 0, 0, err
+			}
+		} else if c.fieldName != "" {
+			structStart, structEnd = c.fieldRange(st.node, patterns, found)
+			if structStart == 0 {
+				continue
+			}
+		}
+		c.structRanges = append(c.structRanges, lineRange{pos: st.node.Pos(), start: structStart, end: structEnd})
+
+		if start == 0 || structStart < start {
+			start = structStart
+		}
+		if structEnd > end {
+			end = structEnd
+		}
+	}
+
+	if len(c.structRanges) == 0 {
+		return 0, 0, fmt.Errorf("none of the structs matching %q has field name %q", c.structName, c.fieldName)
+	}
+
+	// a name might be declared by some of the structs only
+	if c.fieldName != "" && len(matched) > 1 {
+		if missing := missingFields(patterns, found, true); len(missing) != 0 {
+			return 0, 0, fmt.Errorf("none of the structs matching %q has field name%s %s",
+				c.structName, plural(len(missing), "", "s"), strings.Join(missing, ", "))
+		}
+	}
+
+	return start, end, nil
+}
+
+// ambiguousStructError is returned if several matched structs have the same
+// name, i.e. in different functions. Unlike a selection which doesn't exist,
+// it isn't skipped in -dir mode. file is set for the -sync-from reference,
+// which must declare the struct once, -all-matches doesn't apply there.
+type ambiguousStructError struct {
+	file  string
+	name  string
+	lines []int
+}
+
+func (e *ambiguousStructError) Error() string {
+	lines := make([]string, 0, len(e.lines))
+	for _, line := range e.lines {
+		lines = append(lines, strconv.Itoa(line))
+	}
+	if e.file != "" {
+		return fmt.Sprintf("%s: struct name %q is ambiguous, it's declared at lines %s", e.file, e.name, strings.Join(lines, ", "))
+	}
+	return fmt.Sprintf("struct name %q is ambiguous, it's declared at lines %s. pass -all-matches to process all of them",
+		e.name, strings.Join(lines, ", "))
+}
+
+// checkAmbiguousStructs returns an ambiguousStructError for the first name,
+// in source order, shared by several of the matched structs.
+func checkAmbiguousStructs(fset *token.FileSet, matched []*structType) error {
+	lines := make(map[string][]int)
+	var names []string
+	for _, st := range matched {
+		if _, ok := lines[st.name]; !ok {
+			names = append(names, st.name)
+		}
+		lines[st.name] = append(lines[st.name], fset.Position(st.node.Pos()).Line)
+	}
+
+	for _, name := range names {
+		if len(lines[name]) > 1 {
+			return &ambiguousStructError{name: name, lines: lines[name]}
+		}
+	}
+	return nil
+}
+
+// matchStructs returns the structs matching -struct, in source order.
+func (c *config) matchStructs(file ast.Node) []*structType {
+	var matched []*structType
+	for _, st := range collectStructs(file, !c.noDeref) {
+		if st.name != "" && matchName(c.structName, st.name) {
+			matched = append(matched, st)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].node.Pos() < matched[j].node.Pos() })
+	return matched
+}
+
+// matchName reports whether the struct or field name matches any of the
+// comma separated names or glob patterns.
+func matchName(patterns, name string) bool {
+	for _, pattern := range splitPatterns(patterns) {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns splits a comma separated list of names or glob patterns.
+func splitPatterns(patterns string) []string {
+	list := strings.Split(patterns, ",")
+	for i, pattern := range list {
+		list[i] = strings.TrimSpace(pattern)
+	}
+	return list
+}
+
+// fieldSelection selects the fields of the struct matching -field, a comma
+// separated list of names or glob patterns, i.e: ID,Name or Created*, and
+// returns the range they span. Only the matching names are changed in
+// rewrite. A name which doesn't match is an error, even if others do.
+func (c *config) fieldSelection(structName string, st *ast.StructType) (int, int, error) {
+	patterns := splitPatterns(c.fieldName)
+	found := make(map[string]bool)
+	start, end := c.fieldRange(st, patterns, found)
+
+	if missing := missingFields(patterns, found, start != 0); len(missing) != 0 {
+		return 0, 0, fmt.Errorf("struct %q doesn't have field name%s %s",
+			structName, plural(len(missing), "", "s"), strings.Join(missing, ", "))
+	}
+
+	return start, end, nil
+}
+
+// fieldRange returns the range spanned by the fields of the struct matching
+// the patterns, or zeros if none does. The matching patterns are added to
+// found.
+func (c *config) fieldRange(st *ast.StructType, patterns []string, found map[string]bool) (int, int) {
+	start, end := 0, 0
+	for _, f := range st.Fields.List {
+		matched := false
+		for _, name := range fieldNames(f) {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, name); ok {
+					found[pattern] = true
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		fieldStart := c.fileSet.Position(f.Pos()).Line
+		fieldEnd := c.fileSet.Position(f.End()).Line
+		if start == 0 || fieldStart < start {
+			start = fieldStart
+		}
+		if fieldEnd > end {
+			end = fieldEnd
+		}
+	}
+	return start, end
+}
+
+// missingFields returns the quoted -field patterns which aren't found. Once
+// some pattern matched, only the plain names are reported, a glob pattern
+// isn't expected to match every time.
+func missingFields(patterns []string, found map[string]bool, matched bool) []string {
+	var missing []string
+	for _, pattern := range patterns {
+		if found[pattern] || (matched && strings.ContainsAny(pattern, `*?[\`)) {
+			continue
+		}
+		missing = append(missing, strconv.Quote(pattern))
+	}
+	return missing
+}
+
+// structIndexSelection selects the struct at the given one based index, in
+// source order. This is useful for anonymous structs or repeated names.
+func (c *config) structIndexSelection(file ast.Node) (int, int, error) {
+	structs := collectStructs(file, !c.noDeref)
+	if c.structIndex < 1 || c.structIndex > len(structs) {
+		return 0, 0, fmt.Errorf("wrong struct index %d. the file has %d struct(s)", c.structIndex, len(structs))
+	}
+
+	positions := make([]token.Pos, 0, len(structs))
+	for pos := range structs {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+
+	encStruct := structs[positions[c.structIndex-1]].node
+
+	if c.fieldName != "" {
+		return c.fieldSelection(fmt.Sprintf("#%d", c.structIndex), encStruct)
+	}
+
+	start := c.fileSet.Position(encStruct.Pos()).Line
+	end := c.fileSet.Position(encStruct.End()).Line
+
+	return start, end, nil
+}
+
+// lookupStruct returns the struct with the given name, or nil if there is no
+// such struct in the file.
+func (c *config) lookupStruct(file ast.Node, name string) *ast.StructType {
+	structs := collectStructs(file, !c.noDeref)
+
+	var encStruct *ast.StructType
+	for _, st := range structs {
+		if st.name == name {
+			encStruct = st.node
+		}
+	}
+	return encStruct
+}
+
+// pathSelection selects the field addressed by a dotted path starting with
+// the struct name, i.e: Outer.Inner.Field. Fields in the middle of the path
+// must be inline structs, or named structs in semantic mode.
+func (c *config) pathSelection(file ast.Node) (int, int, error) {
+	parts := strings.Split(c.path, ".")
+
+	st := c.lookupStruct(file, parts[0])
+	if st == nil {
+		return 0, 0, fmt.Errorf("struct name %q does not exist", parts[0])
+	}
+
+	var encField *ast.Field
+	for i, name := range parts[1:] {
+		parent := strings.Join(parts[:i+1], ".")
+
+		if encField != nil {
+			st = c.fieldStruct(file, encField)
+			if st == nil {
+				return 0, 0, fmt.Errorf("field %q is not a struct", parent)
+			}
+		}
+
+		encField = nil
+		for _, f := range st.Fields.List {
+			if hasName(f, name) {
+				encField = f
+			}
+		}
+
+		if encField == nil {
+			return 0, 0, fmt.Errorf("struct %q doesn't have field name %q", parent, name)
+		}
+	}
+
+	start := c.fileSet.Position(encField.Pos()).Line
+	end := c.fileSet.Position(encField.End()).Line
+
+	return start, end, nil
+}
+
+// fieldStruct returns the struct type of the field. Inline structs are
+// returned as is, named structs are only resolved in semantic mode.
+func (c *config) fieldStruct(file ast.Node, f *ast.Field) *ast.StructType {
+	if st, ok := deref(f.Type).(*ast.StructType); ok {
+		return st
+	}
+
+	if c.semantic {
+		return c.namedStruct(file, f.Type)
+	}
+	return nil
+}
+
+// allSelection selects all structs inside a file
+func (c *config) allSelection(file ast.Node) (int, int, error) {
+	start := 1
+	end := c.fileSet.File(file.Pos()).LineCount()
+
+	return start, end, nil
+}
+
+func isPublicName(name string) bool {
+	for _, c := range name {
+		return unicode.IsUpper(c)
+	}
+	return false
+}
+
+// rewrite rewrites the node for structs between the start and end
+// positions
+func (c *config) rewrite(node ast.Node, start, end int) (ast.Node, error) {
+	if c.abortOnAmbiguousFrom {
+		if err := c.checkAmbiguousFrom(node, start, end); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkImportConflict(node); err != nil {
+		return nil, err
+	}
+
+	c.visited = make(map[*ast.Field]bool)
+	c.changes = nil
+	c.structChanges = make(map[*ast.StructType]int)
+
+	c.owners = make(map[*ast.Field]*structType)
+	c.splitOrigins = make(map[*ast.Field]splitOrigin)
+	for _, st := range collectStructs(node, true) {
+		for _, f := range st.node.Fields.List {
+			c.owners[f] = st
+		}
+	}
+
+	rewriteFunc := func(n ast.Node) bool {
+		if c.constraintFrom != "" {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				c.rewriteConstraints(spec, start, end)
+			}
+			return true
+		}
+
+		if spec, ok := n.(*ast.TypeSpec); ok && c.scope == scopeTypeDecl {
+			line := c.fileSet.Position(spec.Pos()).Line
+			if start <= line && line <= end {
+				c.rewriteTypeDecl(spec)
+			}
+			return true
+		}
+
+		if spec, ok := n.(*ast.ValueSpec); ok && c.scope == scopeVars && spec.Type != nil {
+			line := c.fileSet.Position(spec.Pos()).Line
+			if start <= line && line <= end {
+				c.rewriteValueSpec(spec)
+			}
+			return true
+		}
+
+		for _, fields := range c.scopeFields(n) {
+			for i, f := range fields.List {
+				if c.inSelection(f, start, end) && c.inStride(i) {
+					c.rewriteField(f)
+				}
+			}
+		}
+
+		return true
+	}
+
+	ast.Inspect(node, rewriteFunc)
+	if file, ok := node.(*ast.File); ok {
+		c.dropReplacedComments(file)
+	}
+
+	c.start = start
+	c.end = end
+
+	return node, nil
+}
+
+// dropReplacedComments removes the comments inside replaced types, i.e: of
+// the parameters of a multi-line func type. They have no place in the new
+// type, the printer would put them before it.
+func (c *config) dropReplacedComments(file *ast.File) {
+	if len(c.replacedTypes) == 0 {
+		return
+	}
+
+	comments := file.Comments[:0]
+	for _, cg := range file.Comments {
+		inside := false
+		for _, t := range c.replacedTypes {
+			if t.Pos() < cg.Pos() && cg.End() <= t.End() {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			comments = append(comments, cg)
+		}
+	}
+	file.Comments = comments
+	c.replacedTypes = nil
+}
+
+// hasSkipDirective reports whether the line comment of the field starts with
+// the skip directive, i.e: "//gomodifytype:skip".
+func (c *config) hasSkipDirective(f *ast.Field) bool {
+	if c.skipDirective == "" || f.Comment == nil {
+		return false
+	}
+
+	for _, comment := range f.Comment.List {
+		text := strings.TrimPrefix(comment.Text, "//")
+		text = strings.TrimPrefix(text, "/*")
+		if strings.HasPrefix(strings.TrimSpace(text), c.skipDirective) {
+			return true
+		}
+	}
+	return false
+}
+
+// inSelection reports whether the field overlaps the start and end lines and
+// is in the -offset-range, if any. A field might span several lines, i.e. if
+// its type is a multi-line func or struct type, so any of its lines can be
+// used to select it.
+func (c *config) inSelection(f *ast.Field, start, end int) bool {
+	pos := c.fileSet.Position(f.Pos())
+	endLine := c.fileSet.Position(f.End()).Line
+	if endLine < start || pos.Line > end {
+		return false
+	}
+
+	if c.offsetRange != "" && !(c.startOffset <= pos.Offset && pos.Offset <= c.endOffset) {
+		return false
+	}
+
+	if c.excludeLine != "" && c.excludeStart <= pos.Line && pos.Line <= c.excludeEnd {
+		return false
+	}
+
+	if c.blameLines != nil && !anyLine(c.blameLines, pos.Line, endLine) {
+		return false
+	}
+
+	if c.lineRanges != nil && !overlapsAny(c.lineRanges, pos.Line, endLine) {
+		return false
+	}
+
+	if c.structRanges != nil && !overlapsAny(c.structRanges, pos.Line, endLine) {
+		return false
+	}
+
+	if c.onlyLineSet != nil {
+		return anyLine(c.onlyLineSet, pos.Line, endLine)
+	}
+
+	return true
+}
+
+// overlapsAny reports whether the lines between start and end overlap any of
+// the ranges.
+func overlapsAny(ranges []lineRange, start, end int) bool {
+	for _, r := range ranges {
+		if start <= r.end && r.start <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// anyLine reports whether any line between start and end is in the set.
+func anyLine(set map[int]bool, start, end int) bool {
+	for line := start; line <= end; line++ {
+		if set[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// limitReached reports whether -limit-per-struct fields of the struct
+// declaring the field are already changed.
+func (c *config) limitReached(f *ast.Field) bool {
+	st, ok := c.owners[f]
+	return ok && c.limitPerStruct > 0 && c.structChanges[st.node] >= c.limitPerStruct
+}
+
+// inStride reports whether the field at the given index of its field list is
+// selected by -field-stride, i.e: every second field for a stride of 2,
+// starting with the first one.
+func (c *config) inStride(i int) bool {
+	return c.fieldStride <= 1 || i%c.fieldStride == 0
+}
+
+// scopeFields returns the lists of fields of the node which are processed in
+// the configured -scope, i.e. both parameters and results of a function.
+func (c *config) scopeFields(n ast.Node) []*ast.FieldList {
+	var lists []*ast.FieldList
+	switch c.scope {
+	case scopeTypeParams:
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			lists = append(lists, x.Type.TypeParams)
+		case *ast.TypeSpec:
+			lists = append(lists, x.TypeParams)
+		}
+	case scopeTypeDecl, scopeVars:
+		// declarations don't have fields, see rewriteTypeDecl and
+		// rewriteValueSpec
+	case scopeFuncs:
+		// function literals and methods of interfaces aren't declarations
+		if x, ok := n.(*ast.FuncDecl); ok {
+			lists = append(lists, x.Type.Params, x.Type.Results)
+		}
+	default:
+		if x, ok := n.(*ast.StructType); ok {
+			lists = append(lists, x.Fields)
+		}
+	}
+
+	nonNil := lists[:0]
+	for _, list := range lists {
+		if list != nil {
+			nonNil = append(nonNil, list)
+		}
+	}
+	return nonNil
+}
+
+// rewriteField rewrites the type of a single field if it matches. Each field
+// is processed at most once, even if it's reached both by the line selection
+// and by descending into an enclosing struct.
+func (c *config) rewriteField(f *ast.Field) {
+	if c.visited[f] {
+		return
+	}
+	c.visited[f] = true
+
+	if c.hasSkipDirective(f) {
+		return
+	}
+
+	if name := c.selectedName(f); name != "" && c.matchesPointer(f) && !c.limitReached(f) {
+		if c.splitGroup(f) {
+			return
+		}
+		changes := len(c.changes)
+
+		if c.rule != nil {
+			if c.rule.match(newRuleField(f, name, types.ExprString(f.Type))) {
+				c.replaceType(f, name, c.rule.to)
+			}
+		} else if c.deprecated != nil {
+			c.rewriteDeprecated(f, name)
+		} else if c.synced != nil {
+			c.rewriteSynced(f, name)
+		} else if c.typeArg != "" {
+			c.replaceTypeArgs(f, name, f.Type)
+		} else if c.mapKey || c.mapValue {
+			c.replaceMapType(f, name)
+		} else if to, ok := c.matchPair(c.matchedType(f)); ok {
+			c.replaceType(f, name, to)
+		} else if ell, ok := f.Type.(*ast.Ellipsis); ok && c.scope == scopeFuncs {
+			// the element type of a variadic parameter, i.e: int of ...int
+			if to, ok := c.matchPair(ell.Elt); ok {
+				c.replaceExpr(c.ownerName(f), name, f, &ell.Elt, to)
+			}
+		} else if c.deep {
+			c.replaceElem(f, name, f.Type)
+		}
+
+		if c.collapsePointers {
+			c.collapsePointer(f, name)
+		}
+
+		// each name of a group is a field of its own
+		if st, ok := c.owners[f]; ok && len(c.changes) != changes {
+			c.structChanges[st.node] += len(f.Names)
+			if len(f.Names) == 0 {
+				c.structChanges[st.node]++
+			}
+		}
+	}
+
+	if c.recurseStructs {
+		c.rewriteNested(f.Type)
+
+		if f.Names == nil && c.semantic {
+			c.rewriteEmbedded(f)
+		}
+	}
+}
+
+// nameSelected reports whether the name of a field in a group, i.e. B in
+// `A, B, C string`, is selected on its own. With -skip-unexported only the
+// exported names of a group are selected.
+func (c *config) nameSelected(name string) bool {
+	if c.skipUnexportedFields && !isPublicName(name) {
+		return false
+	}
+	return c.fieldName == "" || matchName(c.fieldName, name)
+}
+
+// splitOrigin is where a split off field is in the original file: the names
+// it's split off with and the end offset of their group.
+type splitOrigin struct {
+	names []*ast.Ident
+	end   int
+}
+
+// splitGroup splits a field group with selected and unselected names into
+// a field for each run of selected or unselected names, in source order, and
+// rewrites the selected ones. i.e: `A, B, C string` with B selected becomes
+// A, B and C fields. The new fields keep the tag and the doc and line
+// comments of the group. The group is restored if none of the selected names
+// is changed. It reports whether the group is split. With -limit-per-struct
+// the names past the limit aren't selected, so the group is split at it.
+func (c *config) splitGroup(f *ast.Field) bool {
+	st, ok := c.owners[f]
+	if !ok || len(f.Names) < 2 {
+		return false
+	}
+
+	// with -sync-from the names of a group might have different reference
+	// types, so they're split by their reference type too
+	type run struct {
+		selected bool
+		synced   string
+		names    []*ast.Ident
+	}
+	var runs []run
+	remaining := c.limitPerStruct - c.structChanges[st.node]
+	for _, name := range f.Names {
+		selected, synced := c.nameSelected(name.Name), c.synced[name.Name]
+		if selected && c.limitPerStruct > 0 {
+			selected = remaining > 0
+			remaining--
+		}
+		if len(runs) == 0 || runs[len(runs)-1].selected != selected || runs[len(runs)-1].synced != synced {
+			runs = append(runs, run{selected: selected, synced: synced})
+		}
+		runs[len(runs)-1].names = append(runs[len(runs)-1].names, name)
+	}
+	if len(runs) == 1 {
+		return false
+	}
+
+	// the new fields are positioned after the line comment of the group, so
+	// the comment is kept on the group's line
+	pos := f.End()
+	if f.Comment != nil {
+		pos = f.Comment.End()
+	}
+	end := c.fileSet.Position(f.End()).Offset
+
+	// the group keeps the first run, the others get a field of their own
+	split := []*ast.Field{f}
+	for _, r := range runs[1:] {
+		field := &ast.Field{Type: cloneExpr(f.Type, pos)}
+		for _, name := range r.names {
+			field.Names = append(field.Names, &ast.Ident{NamePos: pos, Name: name.Name})
+		}
+		if f.Tag != nil {
+			field.Tag = &ast.BasicLit{ValuePos: pos, Kind: f.Tag.Kind, Value: f.Tag.Value}
+		}
+		// the new fields have no place in the original file, their changes
+		// are recorded at their first name instead
+		c.splitOrigins[field] = splitOrigin{names: r.names, end: end}
+		split = append(split, field)
+	}
+
+	// the list is copied, so the rewrite loop over the current one isn't
+	// affected
+	fields := st.node.Fields
+	old := fields.List
+	list := make([]*ast.Field, 0, len(old)+len(split)-1)
+	for _, field := range old {
+		if field == f {
+			list = append(list, split...)
+		} else {
+			list = append(list, field)
+		}
+	}
+
+	names := f.Names
+	f.Names = runs[0].names
+	fields.List = list
+	for _, field := range split {
+		c.owners[field] = st
+	}
+
+	changes, comments := len(c.changes), len(c.splitComments)
+	if f.Doc != nil || f.Comment != nil {
+		for _, field := range split[1:] {
+			c.splitComments = append(c.splitComments, splitComments{field: field, doc: f.Doc, comment: f.Comment})
+		}
+	}
+	delete(c.visited, f)
+	for i, field := range split {
+		if !runs[i].selected {
+			continue
+		}
+
+		c.rewriteField(field)
+	}
+	c.visited[f] = true
+
+	if len(c.changes) == changes {
+		c.splitComments = c.splitComments[:comments]
+		f.Names = names
+		fields.List = old
+		for _, field := range split[1:] {
+			delete(c.owners, field)
+			delete(c.splitOrigins, field)
+		}
+	}
+	return true
+}
+
+// replaceType replaces the type of the field and records the change.
+func (c *config) replaceType(f *ast.Field, name, to string) {
+	c.replaceExpr(c.ownerName(f), name, f, &f.Type, to)
+}
+
+// ownerName returns the name of the struct declaring the field, if any.
+func (c *config) ownerName(f *ast.Field) string {
+	if st, ok := c.owners[f]; ok {
+		return st.name
+	}
+	return ""
+}
+
+// changeRecords returns the records of the change made to the node. Each name
+// of a field group is a field of its own, so it gets a record of its own at
+// the name. A split off field has no place in the original file, it's
+// recorded where its names are in the group.
+func (c *config) changeRecords(node ast.Node, ch change) []change {
+	f, ok := node.(*ast.Field)
+	if !ok {
+		return []change{ch}
+	}
+
+	names := f.Names
+	if origin, ok := c.splitOrigins[f]; ok {
+		names, ch.end = origin.names, origin.end
+	} else if len(names) < 2 {
+		return []change{ch}
+	}
+
+	records := make([]change, 0, len(names))
+	for _, name := range names {
+		position := c.fileSet.Position(name.Pos())
+		ch.Field = name.Name
+		ch.Line, ch.Column, ch.Offset = position.Line, position.Column, position.Offset
+		records = append(records, ch)
+	}
+	return records
+}
+
+// replaceExpr replaces the type expression and records the change as made to
+// the named field, or declaration, node.
+func (c *config) replaceExpr(structName, name string, node ast.Node, t *ast.Expr, to string) {
+	pos := node.Pos()
+	if isTemplate(to) {
+		expanded := expandTarget(to, name, types.ExprString(*t))
+		expr, err := c.parseTarget(expanded)
+		if err != nil {
+			c.warnf(pos, "%q expands to %q for %s, which is not a valid type", to, expanded, name)
+			return
+		}
+		if qualified := unexportedQualified(expr); qualified != "" {
+			c.warnf(pos, "%q expands to %q for %s, %s is not exported", to, expanded, name, qualified)
+			return
+		}
+		to = expanded
+	}
+
+	if c.semantic {
+		c.checkTarget(*t, to)
+	}
+
+	position := c.fileSet.Position(pos)
+	records := c.changeRecords(node, change{
+		Struct: structName,
+		Field:  name,
+		From:   types.ExprString(*t),
+		To:     to,
+		File:   position.Filename,
+		Line:   position.Line,
+		Column: position.Column,
+		Offset: position.Offset,
+		end:    c.fileSet.Position(node.End()).Offset,
+	})
+
+	if c.confirm == confirmField && !c.confirmChange(records) {
+		c.declined += len(records)
+		return
+	}
+	c.changes = append(c.changes, records...)
+
+	if c.outputFormat == outputJSONL {
+		for _, ch := range records {
+			c.streamChange(ch)
+		}
+	}
+
+	// keep the position of the replaced type, otherwise the printer might
+	// think the field spans several lines. A type following the field name
+	// is put on its last line, so the printer doesn't keep the lines of a
+	// multi-line type as empty ones
+	typePos := (*t).Pos()
+	if typePos != pos {
+		typePos = (*t).End() - 1
+	}
+	c.replacedTypes = append(c.replacedTypes, *t)
+
+	expr, err := c.parseTarget(to)
+	if err != nil {
+		// validate rejects broken targets, keep whatever was passed otherwise
+		*t = &ast.Ident{NamePos: typePos, Name: to}
+		return
+	}
+	*t = cloneExpr(expr, typePos)
+}
+
+// isTemplate reports whether the target type references the field name or
+// its current type.
+func isTemplate(to string) bool {
+	return strings.Contains(to, "$NAME") || strings.Contains(to, "$FROM")
+}
+
+// expandTarget replaces $NAME in the target type with the name of the field,
+// and $FROM with its current type, i.e: internal.Typed$NAME.
+func expandTarget(to, name, from string) string {
+	return strings.NewReplacer("$NAME", name, "$FROM", from).Replace(to)
+}
+
+// parseTarget parses the type expression fields are changed to. It's only
+// parsed once, each replaced type gets its own copy of the result.
+func (c *config) parseTarget(to string) (ast.Expr, error) {
+	if expr, ok := c.targets[to]; ok {
+		return expr, nil
+	}
+
+	expr, err := parser.ParseExpr(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.targets == nil {
+		c.targets = make(map[string]ast.Expr)
+	}
+	c.targets[to] = expr
+	return expr, nil
+}
+
+var (
+	posType    = reflect.TypeOf(token.NoPos)
+	objectType = reflect.TypeOf((*ast.Object)(nil))
+)
+
+// cloneExpr returns a deep copy of the expression with all of its valid
+// positions set to pos, so it's printed in place of the type it replaces.
+// Resolved objects aren't copied, they don't belong to the rewritten file.
+func cloneExpr(x ast.Expr, pos token.Pos) ast.Expr {
+	return cloneValue(reflect.ValueOf(x), pos).Interface().(ast.Expr)
+}
+
+func cloneValue(v reflect.Value, pos token.Pos) reflect.Value {
+	if v.Type() == posType {
+		// missing positions are meaningful to the printer, i.e: a result
+		// list without parentheses, so they're kept as is
+		if token.Pos(v.Int()).IsValid() {
+			return reflect.ValueOf(pos)
+		}
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Type() == objectType {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(cloneValue(v.Elem(), pos))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem(), pos))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), pos))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(cloneValue(v.Field(i), pos))
+		}
+		return c
+	}
+	return v
+}
+
+// rewriteTypeDecl rewrites the type of a type declaration if it matches
+// -from. Otherwise, the element types of arrays, slices, maps and channels
+// are matched, i.e: type IDs = []Old.
+func (c *config) rewriteTypeDecl(spec *ast.TypeSpec) {
+	var rewriteElem func(t *ast.Expr)
+	rewriteElem = func(t *ast.Expr) {
+		if to, ok := c.matchPair(*t); ok {
+			c.replaceExpr("", spec.Name.Name, spec, t, to)
+			return
+		}
+
+		switch x := (*t).(type) {
+		case *ast.ArrayType:
+			rewriteElem(&x.Elt)
+		case *ast.MapType:
+			rewriteElem(&x.Key)
+			rewriteElem(&x.Value)
+		case *ast.ChanType:
+			rewriteElem(&x.Value)
+		}
+	}
+
+	rewriteElem(&spec.Type)
+}
+
+// rewriteValueSpec rewrites the explicit type of a var or const declaration,
+// i.e: var timeout int, if it matches -from.
+func (c *config) rewriteValueSpec(spec *ast.ValueSpec) {
+	if to, ok := c.matchPair(spec.Type); ok {
+		names := make([]string, len(spec.Names))
+		for i, name := range spec.Names {
+			names[i] = name.Name
+		}
+		c.replaceExpr("", strings.Join(names, ", "), spec, &spec.Type, to)
+	}
+}
+
+// collapsePointer normalizes a pointer to a pointer field type, i.e: **T, to
+// a single pointer.
+func (c *config) collapsePointer(f *ast.Field, name string) {
+	star, ok := f.Type.(*ast.StarExpr)
+	if !ok {
+		return
+	}
+
+	base := star.X
+	for {
+		inner, ok := base.(*ast.StarExpr)
+		if !ok {
+			break
+		}
+		base = inner.X
+	}
+
+	if base != star.X {
+		c.replaceType(f, name, "*"+types.ExprString(base))
+	}
+}
+
+// replaceTypeArgs replaces the type arguments of generic instantiations in t
+// which match -type-arg with -to, keeping the generic type, i.e:
+// pkg.Container[Old] becomes pkg.Container[New]. Instantiations nested in
+// pointers, slices, maps and other type arguments are replaced too.
+func (c *config) replaceTypeArgs(f *ast.Field, name string, t ast.Expr) {
+	var args []*ast.Expr
+	switch x := t.(type) {
+	case *ast.StarExpr:
+		c.replaceTypeArgs(f, name, x.X)
+	case *ast.ArrayType:
+		c.replaceTypeArgs(f, name, x.Elt)
+	case *ast.MapType:
+		c.replaceTypeArgs(f, name, x.Key)
+		c.replaceTypeArgs(f, name, x.Value)
+	case *ast.IndexExpr:
+		args = append(args, &x.Index)
+	case *ast.IndexListExpr:
+		for i := range x.Indices {
+			args = append(args, &x.Indices[i])
+		}
+	}
+
+	for _, arg := range args {
+		if c.matchesFrom(*arg, c.typeArg) {
+			c.replaceExpr(c.ownerName(f), name, f, arg, c.typePairs()[0].to)
+		} else {
+			c.replaceTypeArgs(f, name, *arg)
+		}
+	}
+}
+
+// replaceMapType replaces the key type of a map field with -map-key, or its
+// value type with -map-value, if it matches -from. Other fields are left
+// alone.
+func (c *config) replaceMapType(f *ast.Field, name string) {
+	m, ok := f.Type.(*ast.MapType)
+	if !ok {
+		return
+	}
+
+	side := &m.Value
+	if c.mapKey {
+		side = &m.Key
+	}
+
+	if to, ok := c.matchPair(*side); ok {
+		c.replaceExpr(c.ownerName(f), name, f, side, to)
+	}
+}
+
+// replaceElem replaces the element types of the pointer, slice, array or map
+// type t matching -from, keeping the types wrapping them. i.e: *string and
+// []*string become *[]byte and []*[]byte when string is replaced with []byte.
+// Type arguments, i.e: List[string], and the terms of type parameter
+// constraints, i.e: ~string | int, are replaced the same way.
+func (c *config) replaceElem(f *ast.Field, name string, t ast.Expr) {
+	var elems []*ast.Expr
+	switch x := t.(type) {
+	case *ast.StarExpr:
+		elems = append(elems, &x.X)
+	case *ast.ArrayType:
+		elems = append(elems, &x.Elt)
+	case *ast.MapType:
+		elems = append(elems, &x.Value)
+	case *ast.IndexExpr:
+		elems = append(elems, &x.Index)
+	case *ast.IndexListExpr:
+		for i := range x.Indices {
+			elems = append(elems, &x.Indices[i])
+		}
+	case *ast.BinaryExpr:
+		if x.Op == token.OR {
+			elems = append(elems, &x.X, &x.Y)
+		}
+	case *ast.UnaryExpr:
+		if x.Op == token.TILDE {
+			elems = append(elems, &x.X)
+		}
+	case *ast.InterfaceType:
+		// the embedded elements of a constraint, i.e: interface{ int | string }
+		for _, m := range x.Methods.List {
+			if m.Names == nil {
+				elems = append(elems, &m.Type)
+			}
+		}
+	}
+
+	for _, elem := range elems {
+		if to, ok := c.matchPair(*elem); ok {
+			c.replaceExpr(c.ownerName(f), name, f, elem, to)
+		} else {
+			c.replaceElem(f, name, *elem)
+		}
+	}
+}
+
+// matchesPointer reports whether the field passes the -only-pointers and
+// -only-non-pointers filters.
+func (c *config) matchesPointer(f *ast.Field) bool {
+	_, isPointer := f.Type.(*ast.StarExpr)
+	if c.onlyPointers {
+		return isPointer
+	}
+	if c.onlyNonPointers {
+		return !isPointer
+	}
+	return true
+}
+
+// matchedType returns the part of the field type which is compared against
+// -from. That's the pointee with -only-pointers, and the whole type otherwise.
+func (c *config) matchedType(f *ast.Field) ast.Expr {
+	if star, ok := f.Type.(*ast.StarExpr); ok && c.onlyPointers {
+		return star.X
+	}
+	return f.Type
+}
+
+// typePairs returns the -from and -to pairs in the order they're passed,
+// swapped with -reverse. The -to types are normalized once the pairs are
+// built by parseTargets.
+func (c *config) typePairs() []typePair {
+	if c.pairs != nil {
+		return c.pairs
+	}
+
+	froms, tos := c.froms, c.tos
+	if len(froms) == 0 {
+		froms, tos = []string{c.from}, []string{c.to}
+	}
+	if c.reverse {
+		froms, tos = tos, froms
+	}
+
+	pairs := make([]typePair, 0, len(froms))
+	for i, from := range froms {
+		pairs = append(pairs, typePair{from: from, to: tos[i]})
+	}
+	return pairs
+}
+
+// matchPair returns the -to type of the first -from matching the type
+// expression. Later pairs are never tried once a pair matched, so a field
+// is changed only once in a run.
+func (c *config) matchPair(t ast.Expr) (string, bool) {
+	for _, pair := range c.typePairs() {
+		if c.matchesFrom(t, pair.from) {
+			return pair.to, true
+		}
+	}
+	return "", false
+}
+
+// matchesFrom reports whether the type expression matches from. In semantic
+// mode the types are compared by identity, if they can be resolved.
+func (c *config) matchesFrom(t ast.Expr, from string) bool {
+	if c.fromUnderlying != "" {
+		return c.underlyingMatch(t)
+	}
+
+	if c.fromSize > 0 {
+		return c.sizeMatch(t)
+	}
+
+	if c.semantic {
+		if match, ok := c.semanticMatch(t, from); ok {
+			return match
+		}
+	}
+	return c.syntacticMatch(t, from)
+}
+
+// syntacticMatch compares the type expression with from structurally, so
+// spelling differences like map[string] int don't matter. If from isn't a
+// valid expression, the string representations are compared instead.
+func (c *config) syntacticMatch(t ast.Expr, from string) bool {
+	fromExpr, ok := c.fromExprs[from]
+	if !ok {
+		if c.fromExprs == nil {
+			c.fromExprs = make(map[string]ast.Expr)
+		}
+		fromExpr, _ = parser.ParseExpr(from)
+		c.fromExprs[from] = fromExpr
+	}
+
+	if fromExpr == nil {
+		return types.ExprString(t) == from
+	}
+	return exprEqual(t, fromExpr)
+}
+
+// builtinAliases maps the builtin alias types to the types they stand for.
+var builtinAliases = map[string]string{
+	"byte": "uint8",
+	"rune": "int32",
+}
+
+// exprEqual reports whether both type expressions are structurally the same,
+// ignoring positions and parentheses. The builtin byte and rune aliases are
+// equal to uint8 and int32. Expressions which aren't handled explicitly, like
+// func or struct types, are compared by their string representation.
+func exprEqual(a, b ast.Expr) bool {
+	if p, ok := a.(*ast.ParenExpr); ok {
+		return exprEqual(p.X, b)
+	}
+	if p, ok := b.(*ast.ParenExpr); ok {
+		return exprEqual(a, p.X)
+	}
+
+	switch x := a.(type) {
+	case *ast.Ident:
+		y, ok := b.(*ast.Ident)
+		return ok && canonicalIdent(x.Name) == canonicalIdent(y.Name)
+	case *ast.StarExpr:
+		y, ok := b.(*ast.StarExpr)
+		return ok && exprEqual(x.X, y.X)
+	case *ast.ArrayType:
+		y, ok := b.(*ast.ArrayType)
+		if !ok || (x.Len == nil) != (y.Len == nil) {
+			return false
+		}
+		return (x.Len == nil || exprEqual(x.Len, y.Len)) && exprEqual(x.Elt, y.Elt)
+	case *ast.MapType:
+		y, ok := b.(*ast.MapType)
+		return ok && exprEqual(x.Key, y.Key) && exprEqual(x.Value, y.Value)
+	case *ast.ChanType:
+		y, ok := b.(*ast.ChanType)
+		return ok && x.Dir == y.Dir && exprEqual(x.Value, y.Value)
+	case *ast.SelectorExpr:
+		y, ok := b.(*ast.SelectorExpr)
+		return ok && x.Sel.Name == y.Sel.Name && exprEqual(x.X, y.X)
+	case *ast.Ellipsis:
+		y, ok := b.(*ast.Ellipsis)
+		return ok && (x.Elt == nil) == (y.Elt == nil) && (x.Elt == nil || exprEqual(x.Elt, y.Elt))
+	case *ast.BasicLit:
+		y, ok := b.(*ast.BasicLit)
+		return ok && x.Kind == y.Kind && x.Value == y.Value
+	}
+	return types.ExprString(a) == types.ExprString(b)
+}
+
+// canonicalIdent returns the type a builtin alias stands for, or the name
+// itself.
+func canonicalIdent(name string) string {
+	if alias, ok := builtinAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// selectedName returns the name of the field if it passes the field level
+// filters and should have its type compared against -from. An empty string is
+// returned otherwise.
+func (c *config) selectedName(f *ast.Field) string {
+	if c.onlyUntagged && f.Tag != nil {
+		return ""
+	}
+
+	if st := c.owners[f]; c.requireTags && (st == nil || !st.tagged) {
+		return ""
+	}
+
+	if st := c.owners[f]; c.testTables && (st == nil || !st.table) {
+		return ""
+	}
+
+	if c.fieldCommentRe != nil && !c.fieldCommentRe.MatchString(f.Doc.Text()+f.Comment.Text()) {
+		return ""
+	}
+
+	if c.fromExported && !isExportedType(c.matchedType(f)) {
+		return ""
+	}
+
+	fieldName := ""
+	if len(f.Names) != 0 {
+		for _, field := range f.Names {
+			if c.nameSelected(field.Name) {
+				fieldName = field.Name
+				break
+			}
+		}
+	}
+
+	// parameters and results don't need a name
+	if f.Names == nil && c.scope == scopeFuncs {
+		return "_"
+	}
+
+	// anonymous field
+	if f.Names == nil {
+		if name := embeddedName(f.Type); name != "" && c.nameSelected(name) {
+			fieldName = name
+		}
+	}
+
+	return fieldName
+}
+
+// embeddedName returns the field name of an embedded type, i.e: Buffer for
+// *bytes.Buffer, or "" if it can't be embedded.
+func embeddedName(t ast.Expr) string {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+
+	switch x := t.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	}
+	return ""
+}
+
+// hasName reports whether the field, or one of a field group, has the name.
+// Embedded fields are named after their type.
+func hasName(f *ast.Field, name string) bool {
+	if f.Names == nil {
+		return embeddedName(f.Type) == name
+	}
+
+	for _, field := range f.Names {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldNames returns the names of the field, or the name of an embedded
+// field.
+func fieldNames(f *ast.Field) []string {
+	if f.Names == nil {
+		if name := embeddedName(f.Type); name != "" {
+			return []string{name}
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(f.Names))
+	for _, name := range f.Names {
+		names = append(names, name.Name)
+	}
+	return names
+}
+
+// isExportedType reports whether the type expression is an exported name,
+// either local, i.e: Foo, or qualified, i.e: pkg.Foo.
+func isExportedType(t ast.Expr) bool {
+	switch x := t.(type) {
+	case *ast.Ident:
+		return isPublicName(x.Name)
+	case *ast.SelectorExpr:
+		return isPublicName(x.Sel.Name)
+	}
+	return false
+}
+
+// rewriteNested descends into an inline struct type and rewrites all of its
+// fields, regardless of the line selection. Pointers, slices, arrays and map
+// values are followed to reach the struct, i.e: []struct{ X Old }. With
+// -max-depth, structs nested deeper than that are left as is.
+func (c *config) rewriteNested(t ast.Expr) {
+	switch x := t.(type) {
+	case *ast.StarExpr:
+		c.rewriteNested(x.X)
+	case *ast.ArrayType:
+		c.rewriteNested(x.Elt)
+	case *ast.MapType:
+		c.rewriteNested(x.Value)
+	case *ast.StructType:
+		if c.maxDepth > 0 && c.depth >= c.maxDepth {
+			return
+		}
+		c.depth++
+		defer func() { c.depth-- }()
+
+		for i, f := range x.Fields.List {
+			if c.inStride(i) {
+				c.rewriteField(f)
+			}
+		}
+	}
+}
+
+// validate validates whether the config is valid or not
+func (c *config) validate() error {
+	if c.file == "" && c.dir == "" {
+		return errors.New("no file is passed")
+	}
+
+	if c.dir != "" {
+		if c.file != "" {
+			return errors.New("-file or -dir cannot be used together. pick one")
+		}
+
+		if c.line != "" || c.offset != "" || c.offsetRange != "" || c.lspPosition != "" || c.onlyLines != "" {
+			return errors.New("-line, -offset, -offset-range, -lsp-position and -only-lines cannot be used with -dir")
+		}
+	}
+
+	if c.file == stdinFile {
+		if c.write {
+			return errors.New("-w cannot be used when reading from stdin")
+		}
+
+		if c.confirm != "" || c.blameAuthor != "" {
+			return errors.New("-confirm and -blame-author cannot be used when reading from stdin")
+		}
+	}
+
+	// there is nothing to select when the file is only formatted
+	if c.formatOnly {
+		if c.dir != "" {
+			return errors.New("-format-only cannot be used with -dir")
+		}
+
+		if c.line != "" || c.structName != "" || c.structIndex != 0 || c.path != "" || c.offset != "" || c.offsetRange != "" || c.lspPosition != "" || c.onlyLines != "" || c.all || c.fieldName != "" || c.excludeLine != "" {
+			return errors.New("-format-only cannot be used with -line, -struct, -field, -all or other selections")
+		}
+
+		if c.from != "" || c.to != "" || c.ruleSrc != "" || c.deprecatedFile != "" || c.syncFrom != "" || c.retypeConstraint != "" {
+			return errors.New("-format-only cannot be used together with -from, -to, -rule, -deprecated-types, -sync-from or -retype-constraint")
+		}
+	}
+
+	if !c.formatOnly && c.line == "" && c.structName == "" && c.structIndex == 0 && c.path == "" && c.offset == "" && c.offsetRange == "" && c.lspPosition == "" && c.onlyLines == "" && !c.all {
+		return errors.New("-line, -struct, -struct-index, -path, -offset, -offset-range, -lsp-position, -only-lines or -all is not passed")
+	}
+
+	if c.path != "" && strings.Count(c.path, ".") == 0 {
+		return errors.New("-path must be in the form Struct.Field, i.e: Outer.Inner.Field")
+	}
+
+	for _, pattern := range splitPatterns(c.structName) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid -struct pattern %q: %s", pattern, err)
+		}
+	}
+
+	for _, pattern := range splitPatterns(c.fieldName) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid -field pattern %q: %s", pattern, err)
+		}
+	}
+
+	if c.fieldName != "" && c.structName == "" && c.structIndex == 0 {
+		return errors.New("-field is requiring -struct or -struct-index")
+	}
+
+	if !c.multipleSelectors {
+		if err := c.validateSelectors(); err != nil {
+			return err
+		}
+	}
+
+	if c.excludeLine != "" {
+		start, end, err := parseLineRange(c.excludeLine)
+		if err != nil {
+			return fmt.Errorf("invalid -exclude-line: %s", err)
+		}
+		c.excludeStart, c.excludeEnd = start, end
+	}
+
+	switch c.scope {
+	case "", scopeFields, scopeTypeParams, scopeTypeDecl:
+	case scopeFuncs, scopeVars:
+		if c.structName != "" || c.structIndex != 0 || c.path != "" {
+			return fmt.Errorf("-scope %s cannot be used with -struct, -struct-index or -path", c.scope)
+		}
+	default:
+		return fmt.Errorf("unknown -scope %q. expected %s, %s, %s, %s or %s", c.scope, scopeFields, scopeTypeParams, scopeTypeDecl, scopeFuncs, scopeVars)
+	}
+
+	switch c.outputFormat {
+	case "", outputText, outputJSONL, outputSARIF:
+	case outputJSON:
+		c.jsonOutput = true
+	default:
+		return fmt.Errorf("unknown -output-format %q. expected %s, %s, %s or %s", c.outputFormat, outputText, outputJSON, outputJSONL, outputSARIF)
+	}
+
+	if c.jsonOutput && (c.outputFormat == outputJSONL || c.outputFormat == outputSARIF) {
+		return fmt.Errorf("-json cannot be used together with -output-format %s", c.outputFormat)
+	}
+
+	if c.diff && (c.jsonOutput || (c.outputFormat != "" && c.outputFormat != outputText)) {
+		return errors.New("-diff cannot be used together with -json or -output-format")
+	}
+
+	switch c.color {
+	case "", colorAuto, colorAlways, colorNever:
+	default:
+		return fmt.Errorf("unknown -color %q. expected %s, %s or %s", c.color, colorAuto, colorAlways, colorNever)
+	}
+
+	if c.typeGraph {
+		if c.write {
+			return errors.New("-type-graph is read-only, it cannot be used with -w")
+		}
+
+		if c.diff || c.jsonOutput || (c.outputFormat != "" && c.outputFormat != outputText) {
+			return errors.New("-type-graph cannot be used together with -diff, -json or -output-format")
+		}
+	}
+
+	if c.testTables && c.dir == "" && !isTestFile(c.filename()) {
+		return fmt.Errorf("-test-tables is requiring a _test.go file, got %s", c.filename())
+	}
+
+	if c.mapKey && c.mapValue {
+		return errors.New("-map-key or -map-value cannot be used together. pick one")
+	}
+
+	if c.markDone != "" && !isLineComment(c.markDone) {
+		return fmt.Errorf("-mark-done %q should be a single line comment, i.e: // migrated:v2", c.markDone)
+	}
+
+	if c.skipIfMarked && c.markDone == "" {
+		return errors.New("-skip-if-marked is requiring -mark-done")
+	}
+
+	if c.includeVendor && c.dir == "" {
+		return errors.New("-include-vendor is requiring -dir")
+	}
+
+	if c.migrationFile != "" && c.dir != "" {
+		return errors.New("-emit-migration cannot be used with -dir")
+	}
+
+	if c.positions && (c.typeGraph || c.diff || c.jsonOutput || (c.outputFormat != "" && c.outputFormat != outputText)) {
+		return errors.New("-positions cannot be used together with -type-graph, -diff, -json or -output-format")
+	}
+
+	switch c.confirm {
+	case "", confirmField:
+	case confirmFile:
+		if !c.write {
+			return errors.New("-confirm file is requiring -w")
+		}
+	default:
+		return fmt.Errorf("unknown -confirm %q. expected %s or %s", c.confirm, confirmFile, confirmField)
+	}
+
+	if c.fieldCommentRegex != "" {
+		re, err := regexp.Compile(c.fieldCommentRegex)
+		if err != nil {
+			return fmt.Errorf("invalid -field-comment-regex: %s", err)
+		}
+		c.fieldCommentRe = re
+	}
+
+	if c.maxDepth < 0 {
+		return errors.New("-max-depth cannot be negative")
+	}
+
+	if c.fieldStride < 0 {
+		return errors.New("-field-stride cannot be negative")
+	}
+
+	if c.limitPerStruct < 0 {
+		return errors.New("-limit-per-struct cannot be negative")
+	}
+
+	if c.onlyPointers && c.onlyNonPointers {
+		return errors.New("-only-pointers or -only-non-pointers cannot be used together. pick one")
+	}
+
+	// the remaining options are about the types to rewrite
+	if c.formatOnly {
+		return nil
+	}
+
+	if c.abortOnAmbiguousFrom && !c.semantic {
+		return errors.New("-abort-on-ambiguous-from is requiring -semantic")
+	}
+
+	if c.fromUnderlying != "" {
+		if !c.semantic {
+			return errors.New("-from-underlying is requiring -semantic")
+		}
+
+		if c.from != "" {
+			return errors.New("-from-underlying cannot be used together with -from")
+		}
+	}
+
+	if c.fromSize != 0 {
+		if !c.semantic {
+			return errors.New("-from-size is requiring -semantic")
+		}
+
+		if c.fromSize < 0 {
+			return errors.New("-from-size cannot be negative")
+		}
+
+		if c.from != "" || c.fromUnderlying != "" {
+			return errors.New("-from-size cannot be used together with -from or -from-underlying")
+		}
+	}
+
+	if c.typeArg != "" {
+		if c.from != "" || c.fromUnderlying != "" || c.fromSize != 0 || c.ruleSrc != "" || len(c.tos) > 1 {
+			return errors.New("-type-arg cannot be used together with -from, -from-underlying, -from-size, -rule or several -to")
+		}
+
+		if c.to == "" {
+			return errors.New("-type-arg is requiring -to")
+		}
+	}
+
+	if c.from != "" && c.to == "" {
+		return errors.New("-from is requiring -to")
+	}
+
+	if (len(c.froms) > 0 || len(c.tos) > 1) && len(c.froms) != len(c.tos) {
+		return fmt.Errorf("-from is passed %d times and -to %d times, they should be paired", len(c.froms), len(c.tos))
+	}
+
+	if c.reverse {
+		if c.from == "" || c.to == "" {
+			return errors.New("-reverse is requiring -from and -to")
+		}
+
+		// pairs sharing a -to can't be undone, the -to type can only go
+		// back to one of the -from types
+		reversed := make(map[string]string)
+		for _, pair := range c.typePairs() {
+			if from, ok := reversed[pair.from]; ok {
+				return fmt.Errorf("-reverse is conflicting: %s would be changed to both %s and %s", pair.from, from, pair.to)
+			}
+			reversed[pair.from] = pair.to
+		}
+	}
+
+	if c.retypeConstraint != "" {
+		if !c.semantic {
+			return errors.New("-retype-constraint is requiring -semantic")
+		}
+
+		if c.from != "" || c.to != "" || c.ruleSrc != "" || c.fromUnderlying != "" {
+			return errors.New("-retype-constraint cannot be used together with -from, -to, -from-underlying or -rule")
+		}
+
+		from, to, ok := strings.Cut(c.retypeConstraint, "=")
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("-retype-constraint %q should be in the form Old=New", c.retypeConstraint)
+		}
+		c.constraintFrom, c.constraintTo = from, to
+	}
+
+	if c.deprecatedFile != "" {
+		if c.from != "" || c.to != "" || c.ruleSrc != "" {
+			return errors.New("-deprecated-types cannot be used together with -from, -to or -rule")
+		}
+
+		list, err := loadDeprecatedTypes(c.deprecatedFile)
+		if err != nil {
+			return err
+		}
+		c.deprecated = list
+	}
+
+	if c.syncFrom != "" {
+		if c.from != "" || c.to != "" || c.ruleSrc != "" || c.deprecatedFile != "" {
+			return errors.New("-sync-from cannot be used together with -from, -to, -rule or -deprecated-types")
+		}
+
+		if c.structName == "" {
+			return errors.New("-sync-from is requiring -struct")
+		}
+
+		fields, err := loadSyncTypes(c.syncFrom, c.structName)
+		if err != nil {
+			return err
+		}
+		c.synced = fields
+	}
+
+	if c.ruleSrc != "" {
+		if c.from != "" || c.to != "" {
+			return errors.New("-rule cannot be used together with -from or -to")
+		}
+
+		if c.scope == scopeTypeDecl || c.scope == scopeVars {
+			return fmt.Errorf("-rule cannot be used with -scope %s", c.scope)
+		}
+
+		r, err := parseRule(c.ruleSrc)
+		if err != nil {
+			return err
+		}
+		c.rule = r
+	}
+
+	return c.parseTargets()
+}
+
+// validateSelectors rejects combinations of selectors, which are exclusive
+// unless -allow-multiple-selectors is passed. -all can be combined with any
+// of them, it selects the whole file.
+func (c *config) validateSelectors() error {
+	if c.line != "" && c.structName != "" {
+		return errors.New("-line or -struct cannot be used together. pick one")
+	}
+
+	if c.path != "" && (c.line != "" || c.structName != "") {
+		return errors.New("-path cannot be used together with -line or -struct")
+	}
+
+	if c.offset != "" && (c.line != "" || c.structName != "" || c.structIndex != 0 || c.path != "" || c.offsetRange != "" || c.lspPosition != "" || c.onlyLines != "") {
+		return errors.New("-offset cannot be used together with -line, -struct or other selections")
+	}
+
+	if c.offsetRange != "" && (c.line != "" || c.structName != "" || c.path != "") {
+		return errors.New("-offset-range cannot be used together with -line, -struct or -path")
+	}
+
+	if c.lspPosition != "" && (c.line != "" || c.structName != "" || c.path != "" || c.offsetRange != "") {
+		return errors.New("-lsp-position cannot be used together with -line, -struct, -path or -offset-range")
+	}
+
+	if c.onlyLines != "" && (c.line != "" || c.structName != "" || c.structIndex != 0 || c.path != "" || c.offsetRange != "" || c.lspPosition != "") {
+		return errors.New("-only-lines cannot be used together with other selections")
+	}
+
+	if c.structIndex != 0 && (c.line != "" || c.structName != "" || c.path != "" || c.offsetRange != "" || c.lspPosition != "") {
+		return errors.New("-struct-index cannot be used together with -line, -struct, -path, -offset-range or -lsp-position")
+	}
+
+	return nil
+}
+
+// parseTargets parses all types fields might be changed to, so a broken one
+// is reported before any file is touched. Templates are checked with a
+// sample field, the expansion is checked again for each field. The types are
+// normalized here with -normalize-to, the passed -to is left as is.
+func (c *config) parseTargets() error {
+	parse := func(to string) error {
+		if isTemplate(to) {
+			to = expandTarget(to, "Name", "T")
+		}
+		expr, err := c.parseTarget(to)
+		if err != nil {
+			return err
+		}
+		if name := unexportedQualified(expr); name != "" {
+			return fmt.Errorf("%s is not exported, it can't be used outside of its package", name)
+		}
+		return nil
+	}
+
+	c.pairs = nil
+	pairs := c.typePairs()
+	for i, pair := range pairs {
+		if pair.to == "" {
+			continue
+		}
+		if c.normalizeTo {
+			to, err := normalizeType(pair.to)
+			if err != nil {
+				return fmt.Errorf("invalid -to: %s", err)
+			}
+			pairs[i].to = to
+		}
+		if err := parse(pairs[i].to); err != nil {
+			return fmt.Errorf("invalid -to: %s", err)
+		}
+	}
+	c.pairs = pairs
+
+	if c.rule != nil {
+		if c.normalizeTo {
+			to, err := normalizeType(c.rule.to)
+			if err != nil {
+				return fmt.Errorf("invalid -rule type: %s", err)
+			}
+			c.rule.to = to
+		}
+		if err := parse(c.rule.to); err != nil {
+			return fmt.Errorf("invalid -rule type: %s", err)
+		}
+	}
+
+	if c.constraintTo != "" {
+		if err := parse(c.constraintTo); err != nil {
+			return fmt.Errorf("invalid -retype-constraint type: %s", err)
+		}
+	}
+
+	for _, d := range c.deprecated {
+		if d.replacement == "" {
+			continue
+		}
+		if err := parse(d.replacement); err != nil {
+			return fmt.Errorf("invalid replacement of %s in -deprecated-types: %s", d.name, err)
+		}
+	}
+
+	return nil
+}
+
+// unexportedQualified returns the first qualified identifier of the type
+// expression which isn't exported, i.e: pb.message, or "" if there is none.
+// Such a type can't be referenced outside of its package.
+func unexportedQualified(t ast.Expr) string {
+	var name string
+	ast.Inspect(t, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || name != "" {
+			return name == ""
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && !sel.Sel.IsExported() {
+			name = pkg.Name + "." + sel.Sel.Name
+		}
+		return false
+	})
+	return name
+}
+
+// normalizeType parses the type expression and prints it back in gofmt
+// style, i.e: "* pkg . T" becomes "*pkg.T".
+func normalizeType(s string) (string, error) {
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// deref takes an expression, and removes all its leading "*" and "[]"
+// operator. Use case : if found expression is a "*t" or "[]t", we need to
+// check if "t" contains a struct expression.
+func deref(x ast.Expr) ast.Expr {
+	switch t := x.(type) {
+	case *ast.StarExpr:
+		return deref(t.X)
+	case *ast.ArrayType:
+		return deref(t.Elt)
+	}
+	return x
+}
@@ -0,0 +1,1947 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden (.golden) files")
+
+// This is the directory where our test fixtures are.
+const fixtureDir = "./test-fixtures"
+
+func TestRewrite(t *testing.T) {
+	test := []struct {
+		cfg  *config
+		file string
+	}{
+		{
+			file: "field_type_modify",
+			cfg: &config{
+				structName: "foo",
+				fieldName:  "bar",
+				from:       "string",
+				to:         "[]byte",
+			},
+		},
+		{
+			file: "only_untagged",
+			cfg: &config{
+				structName:   "foo",
+				from:         "string",
+				to:           "[]byte",
+				onlyUntagged: true,
+			},
+		},
+		{
+			file: "recurse_structs",
+			cfg: &config{
+				line:           "4",
+				from:           "string",
+				to:             "[]byte",
+				recurseStructs: true,
+			},
+		},
+		{
+			// Opts and Inner are rewritten, Deep is too deep
+			file: "max_depth",
+			cfg: &config{
+				line:           "4",
+				from:           "string",
+				to:             "[]byte",
+				recurseStructs: true,
+				maxDepth:       2,
+			},
+		},
+		{
+			// Name becomes []byte, but isn't changed again by the third pair
+			file: "multiple_pairs",
+			cfg: &config{
+				structName: "foo",
+				from:       "int",
+				to:         "int64",
+				froms:      []string{"int", "string", "[]byte"},
+				tos:        []string{"int64", "[]byte", "Raw"},
+			},
+		},
+		{
+			file: "simplify",
+			cfg: &config{
+				structName: "point",
+				from:       "string",
+				to:         "[]byte",
+				simplify:   true,
+			},
+		},
+		{
+			file: "simplify_shadowed",
+			cfg: &config{
+				structName: "point",
+				from:       "string",
+				to:         "[]byte",
+				simplify:   true,
+			},
+		},
+		{
+			file: "composite_literal",
+			cfg: &config{
+				all:  true,
+				from: "int",
+				to:   "int64",
+			},
+		},
+		{
+			// the literal is selected by the variable it's assigned to
+			file: "composite_literal_named",
+			cfg: &config{
+				structName: "x",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// the wrapping types are kept, map keys aren't matched
+			file: "deep",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+				deep:       true,
+			},
+		},
+		{
+			file: "mark_done",
+			cfg: &config{
+				all:      true,
+				from:     "int",
+				to:       "int64",
+				markDone: "// migrated:v2",
+			},
+		},
+		{
+			// only the key of Same changes, the plain field is left alone
+			file: "map_key",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "fmt.Stringer",
+				mapKey:     true,
+			},
+		},
+		{
+			file: "map_value",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "fmt.Stringer",
+				mapValue:   true,
+			},
+		},
+		{
+			// B is split from the group, A and C keep their type
+			file: "field_group",
+			cfg: &config{
+				structName: "foo",
+				fieldName:  "B",
+				from:       "string",
+				to:         "[]byte",
+			},
+		},
+		{
+			// exported groups change, unexported ones are skipped and only
+			// the exported names of mixed groups change
+			file: "skip_unexported_groups",
+			cfg: &config{
+				structName:           "foo",
+				from:                 "string",
+				to:                   "[]byte",
+				skipUnexportedFields: true,
+			},
+		},
+		{
+			// only maps of string to the empty struct are sets
+			file: "set_types",
+			cfg: &config{
+				structName: "foo",
+				from:       "map[string]struct{}",
+				to:         "mapset.Set[string]",
+			},
+		},
+		{
+			// the pointer is kept by -to
+			file: "embedded_pointer",
+			cfg: &config{
+				structName: "foo",
+				from:       "*bytes.Buffer",
+				to:         "*Buffer",
+			},
+		},
+		{
+			// embedded types are exported if their last ident is
+			file: "embedded_qualified",
+			cfg: &config{
+				structName:           "foo",
+				froms:                []string{"sync.Mutex", "*sync.RWMutex", "mutex", "*local"},
+				tos:                  []string{"Mutex", "*RWMutex", "Mutex", "*Local"},
+				skipUnexportedFields: true,
+			},
+		},
+		{
+			file: "auto_import",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "time.Duration",
+			},
+		},
+		{
+			// pb isn't the last element of the path
+			file: "import_path",
+			cfg: &config{
+				structName: "foo",
+				from:       "[]byte",
+				to:         "*pb.Message",
+				importPath: "github.com/x/protos",
+			},
+		},
+		{
+			file: "import_exists",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "time.Duration",
+			},
+		},
+		{
+			// only the whole instantiation matches
+			file: "qualified_generic",
+			cfg: &config{
+				structName: "foo",
+				from:       "pkg.Container[Old]",
+				to:         "pkg.Container[New]",
+			},
+		},
+		{
+			// the generic types are kept, plain fields aren't type arguments
+			file: "qualified_generic_type_arg",
+			cfg: &config{
+				structName: "foo",
+				typeArg:    "Old",
+				to:         "New",
+			},
+		},
+		{
+			file: "tidy_imports",
+			cfg: &config{
+				structName:  "foo",
+				from:        "time.Duration",
+				to:          "int64",
+				tidyImports: true,
+			},
+		},
+		{
+			// time is still used by the function body
+			file: "tidy_imports_used",
+			cfg: &config{
+				structName:  "foo",
+				from:        "time.Duration",
+				to:          "int64",
+				tidyImports: true,
+			},
+		},
+		{
+			file: "generic_deep",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "int",
+				deep:       true,
+			},
+		},
+		{
+			// the terms of the constraints change, the fields don't
+			file: "generic_constraints",
+			cfg: &config{
+				all:   true,
+				from:  "string",
+				to:    "int",
+				deep:  true,
+				scope: scopeTypeParams,
+			},
+		},
+		{
+			// the split fields keep the source order of the names
+			file: "field_order",
+			cfg: &config{
+				structName:           "foo",
+				from:                 "string",
+				to:                   "[]byte",
+				skipUnexportedFields: true,
+			},
+		},
+		{
+			// the directives stay on the changed fields
+			file: "nolint_directive",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+				froms:      []string{"string", "func(ctx string) error"},
+				tos:        []string{"[]byte", "HandlerFunc"},
+			},
+		},
+		{
+			file: "rule",
+			cfg: &config{
+				all:     true,
+				ruleSrc: `field.Type == "int" && hasTag(field, "money") => "decimal.Decimal"`,
+			},
+		},
+		{
+			file: "no_deref",
+			cfg: &config{
+				structName: "value",
+				from:       "string",
+				to:         "[]byte",
+				noDeref:    true,
+			},
+		},
+		{
+			file: "semantic_byte_alias",
+			cfg: &config{
+				all:      true,
+				from:     "[]byte",
+				to:       "Raw",
+				semantic: true,
+			},
+		},
+		{
+			file: "only_pointers",
+			cfg: &config{
+				all:          true,
+				from:         "Foo",
+				to:           "Foo",
+				onlyPointers: true,
+			},
+		},
+		{
+			file: "only_non_pointers",
+			cfg: &config{
+				all:             true,
+				from:            "Foo",
+				to:              "Bar",
+				onlyNonPointers: true,
+			},
+		},
+		{
+			file: "path_inline",
+			cfg: &config{
+				path: "Outer.Inner.Field",
+				from: "string",
+				to:   "[]byte",
+			},
+		},
+		{
+			file: "path_named",
+			cfg: &config{
+				path:     "Outer.Inner.Field",
+				from:     "string",
+				to:       "[]byte",
+				semantic: true,
+			},
+		},
+		{
+			// field b starts at offset 42, c at 52
+			file: "offset_range",
+			cfg: &config{
+				offsetRange: "42,51",
+				from:        "string",
+				to:          "[]byte",
+			},
+		},
+		{
+			file: "scope_typeparams",
+			cfg: &config{
+				all:   true,
+				from:  "Old",
+				to:    "New",
+				scope: scopeTypeParams,
+			},
+		},
+		{
+			file: "skip_directive",
+			cfg: &config{
+				all:           true,
+				from:          "string",
+				to:            "[]byte",
+				skipDirective: "nolint:retype",
+			},
+		},
+		{
+			file: "scope_typedecl",
+			cfg: &config{
+				all:   true,
+				from:  "Old",
+				to:    "New",
+				scope: scopeTypeDecl,
+			},
+		},
+		{
+			file: "field_comment_regex",
+			cfg: &config{
+				all:               true,
+				from:              "int",
+				to:                "int64",
+				fieldCommentRegex: "(?i)deprecated",
+			},
+		},
+		{
+			file: "recurse_containers",
+			cfg: &config{
+				line:           "4",
+				from:           "Old",
+				to:             "New",
+				recurseStructs: true,
+			},
+		},
+		{
+			file: "from_underlying",
+			cfg: &config{
+				structName:     "User",
+				fromUnderlying: "string",
+				to:             "Text",
+				semantic:       true,
+			},
+		},
+		{
+			// line 4 is the fifth line, with field b
+			file: "lsp_position",
+			cfg: &config{
+				lspPosition: "4:1",
+				from:        "string",
+				to:          "[]byte",
+			},
+		},
+		{
+			file: "collapse_pointers",
+			cfg: &config{
+				all:              true,
+				collapsePointers: true,
+			},
+		},
+		{
+			file: "struct_index",
+			cfg: &config{
+				structIndex: 2,
+				fieldName:   "Name",
+				from:        "string",
+				to:          "[]byte",
+			},
+		},
+		{
+			file: "only_lines",
+			cfg: &config{
+				onlyLines: "4,6,12",
+				from:      "string",
+				to:        "[]byte",
+			},
+		},
+		{
+			// line 5 is in the middle of the Handler type
+			file: "multiline_field",
+			cfg: &config{
+				line: "5",
+				from: "func(ctx string) error",
+				to:   "HandlerFunc",
+			},
+		},
+		{
+			file: "multiline_comments",
+			cfg: &config{
+				all:   true,
+				from:  "func(ctx string) error",
+				to:    "HandlerFunc",
+				froms: []string{"func(ctx string) error", "string"},
+				tos:   []string{"HandlerFunc", "[]byte"},
+			},
+		},
+		{
+			file: "to_later_type",
+			cfg: &config{
+				structName: "User",
+				fieldName:  "ID",
+				from:       "string",
+				to:         "UserID",
+				semantic:   true,
+			},
+		},
+		{
+			file: "field_stride",
+			cfg: &config{
+				structName:  "foo",
+				from:        "string",
+				to:          "[]byte",
+				fieldStride: 2,
+			},
+		},
+		{
+			file: "from_exported",
+			cfg: &config{
+				structName:   "foo",
+				ruleSrc:      `true => "Value"`,
+				fromExported: true,
+			},
+		},
+		{
+			// warns about the import shadowing string
+			file: "import_shadows_builtin",
+			cfg: &config{
+				structName: "foo",
+				from:       "string",
+				to:         "Text",
+				stderr:     ioutil.Discard,
+			},
+		},
+		{
+			// line 13 selects First only, so B keeps its constraint
+			file: "retype_constraint",
+			cfg: &config{
+				line:             "8,13",
+				retypeConstraint: "Old=New",
+				semantic:         true,
+			},
+		},
+		{
+			file: "require_tags",
+			cfg: &config{
+				all:         true,
+				from:        "string",
+				to:          "[]byte",
+				requireTags: true,
+			},
+		},
+		{
+			file: "exclude_line",
+			cfg: &config{
+				structName:  "foo",
+				from:        "string",
+				to:          "[]byte",
+				excludeLine: "5,6",
+			},
+		},
+		{
+			file: "from_size",
+			cfg: &config{
+				structName: "foo",
+				fromSize:   8,
+				to:         "Small",
+				semantic:   true,
+			},
+		},
+		{
+			// Raw resolves in the main package as in any other one, no import
+			// is added for it
+			file: "package_main",
+			cfg: &config{
+				structName: "message",
+				from:       "[]byte",
+				to:         "Raw",
+				semantic:   true,
+			},
+		},
+		{
+			// only gofmt'd, the imports are sorted too
+			file: "format_only",
+			cfg: &config{
+				formatOnly: true,
+			},
+		},
+		{
+			file: "vars_block",
+			cfg: &config{
+				all:   true,
+				scope: scopeVars,
+				from:  "int",
+				to:    "int64",
+			},
+		},
+		{
+			file: "vars_const",
+			cfg: &config{
+				line:  "3,15",
+				scope: scopeVars,
+				from:  "int",
+				to:    "int64",
+			},
+		},
+		{
+			file: "funcs_params",
+			cfg: &config{
+				all:   true,
+				scope: scopeFuncs,
+				from:  "context.Context",
+				to:    "Context",
+			},
+		},
+		{
+			file: "funcs_results",
+			cfg: &config{
+				all:   true,
+				scope: scopeFuncs,
+				from:  "error",
+				to:    "Error",
+			},
+		},
+		{
+			file: "funcs_variadic",
+			cfg: &config{
+				line:  "3",
+				scope: scopeFuncs,
+				from:  "int",
+				to:    "int64",
+			},
+		},
+		{
+			file: "field_patterns",
+			cfg: &config{
+				structName: "foo",
+				fieldName:  "ID, Name, Created*",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// the doc and line comments stay on the changed fields
+			file: "comments_kept",
+			cfg: &config{
+				structName: "foo",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// the directive applies to both fields of the split group, it's
+			// kept once on each of them
+			file: "nolint_split",
+			cfg: &config{
+				structName: "foo",
+				fieldName:  "Max",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// the comments of the group are copied onto Max, and the blank
+			// line after it is kept
+			file: "comments_split",
+			cfg: &config{
+				structName: "foo",
+				fieldName:  "Max",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// the groups are split at the limit, A and B of foo, F and G of
+			// bar
+			file: "limit_per_struct_group",
+			cfg: &config{
+				all:            true,
+				limitPerStruct: 2,
+				from:           "int",
+				to:             "int64",
+			},
+		},
+		{
+			// B and C of foo, E and F of bar, H and I of its nested struct and
+			// K of baz
+			file: "limit_per_struct",
+			cfg: &config{
+				all:            true,
+				limitPerStruct: 2,
+				from:           "int",
+				to:             "int64",
+			},
+		},
+		{
+			file: "ambiguous_struct",
+			cfg: &config{
+				structName: "Foo",
+				allMatches: true,
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// User and UserProfile, the nested struct included
+			file: "struct_glob",
+			cfg: &config{
+				structName: "User*",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			file: "struct_list",
+			cfg: &config{
+				structName: "Order, Account",
+				from:       "int",
+				to:         "int64",
+			},
+		},
+		{
+			// a and b of foo, and f of bar
+			file: "line_ranges",
+			cfg: &config{
+				line: "4,5;12",
+				from: "int",
+				to:   "int64",
+			},
+		},
+		{
+			// the lines 5-11 within foo, but the excluded line 6
+			file: "multiple_selectors",
+			cfg: &config{
+				line:              "5,11",
+				structName:        "foo",
+				excludeLine:       "6",
+				multipleSelectors: true,
+				from:              "int",
+				to:                "int64",
+			},
+		},
+		{
+			// Name has the type of the reference already, Legacy is not in it and
+			// the Age, Rank group is split
+			file: "sync_from",
+			cfg: &config{
+				structName: "User",
+				syncFrom:   filepath.Join(fixtureDir, "sync_from.reference"),
+			},
+		},
+		{
+			// reports Status, which has no replacement
+			file: "deprecated_types",
+			cfg: &config{
+				structName:     "foo",
+				deprecatedFile: filepath.Join(fixtureDir, "deprecated_types.txt"),
+				stderr:         ioutil.Discard,
+			},
+		},
+		{
+			file: "to_template",
+			cfg: &config{
+				structName: "foo",
+				ruleSrc:    `field.Type != "bool" => "internal.Typed$NAME[$FROM]"`,
+			},
+		},
+		{
+			// warns about sync.Mutex being declared in another package
+			file: "recurse_embedded",
+			cfg: &config{
+				structName:     "foo",
+				from:           "string",
+				to:             "Text",
+				recurseStructs: true,
+				semantic:       true,
+				stderr:         ioutil.Discard,
+			},
+		},
+		{
+			// the printer keeps the tag literals verbatim
+			file: "tag_quotes",
+			cfg: &config{
+				all:  true,
+				from: "string",
+				to:   "[]byte",
+			},
+		},
+		{
+			file: "test_tables",
+			cfg: &config{
+				stdinFilename: "parse_test.go",
+				all:           true,
+				from:          "string",
+				to:            "[]byte",
+				testTables:    true,
+			},
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.file, func(t *testing.T) {
+			input := filepath.Join(fixtureDir, fmt.Sprintf("%s.input", ts.file))
+			ts.cfg.file = input
+
+			// the input is passed on stdin if it's named after another file
+			if ts.cfg.stdinFilename != "" {
+				src, err := ioutil.ReadFile(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ts.cfg.file = stdinFile
+				ts.cfg.stdin = bytes.NewReader(src)
+			}
+
+			if err := ts.cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := ts.cfg.process()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []byte(out)
+
+			// update golden file if necessary
+			golden := filepath.Join(fixtureDir, fmt.Sprintf("%s.golden", ts.file))
+			if *update {
+				err := ioutil.WriteFile(golden, got, 0644)
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+
+			// get golden file
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			from, err := ioutil.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// compare
+			if !bytes.Equal(got, want) {
+				t.Errorf("case %s\ngot:\n====\n\n%s\nwant:\n=====\n\n%s\nfrom:\n=====\n\n%s\n",
+					ts.file, got, want, from)
+			}
+		})
+	}
+}
+
+func TestNoDeref(t *testing.T) {
+	for _, name := range []string{"pointer", "slice"} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config{
+				file:       filepath.Join(fixtureDir, "no_deref.input"),
+				structName: name,
+				from:       "string",
+				to:         "[]byte",
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatalf("struct %s should be selected by default: %s", name, err)
+			}
+
+			cfg.noDeref = true
+			if _, err := cfg.process(); err == nil {
+				t.Fatalf("struct %s should not be selected with -no-deref", name)
+			}
+		})
+	}
+}
+
+func TestPathSelectionErrors(t *testing.T) {
+	test := []struct {
+		path    string
+		wantErr string
+	}{
+		{
+			path:    "Outer.Inner.Field",
+			wantErr: `field "Outer.Inner" is not a struct`,
+		},
+		{
+			path:    "Outer.Missing",
+			wantErr: `struct "Outer" doesn't have field name "Missing"`,
+		},
+		{
+			path:    "Missing.Field",
+			wantErr: `struct name "Missing" does not exist`,
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.path, func(t *testing.T) {
+			cfg := &config{
+				file: filepath.Join(fixtureDir, "path_named.input"),
+				path: ts.path,
+				from: "string",
+				to:   "[]byte",
+			}
+
+			_, err := cfg.process()
+			if err == nil || err.Error() != ts.wantErr {
+				t.Errorf("got error %v, want %q", err, ts.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSelection(t *testing.T) {
+	test := []struct {
+		name      string
+		cfg       *config
+		wantCount int
+		wantErr   string
+	}{
+		{
+			name:      "struct",
+			cfg:       &config{structName: "foo"},
+			wantCount: 3,
+		},
+		{
+			name:      "field",
+			cfg:       &config{structName: "foo", fieldName: "qaz"},
+			wantCount: 1,
+		},
+		{
+			name:    "missing field",
+			cfg:     &config{structName: "foo", fieldName: "missing"},
+			wantErr: `struct "foo" doesn't have field name "missing"`,
+		},
+		{
+			name:    "missing struct",
+			cfg:     &config{structName: "missing"},
+			wantErr: "struct name does not exist",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			ts.cfg.file = filepath.Join(fixtureDir, "field_type_modify.input")
+
+			count, err := ts.cfg.validateSelection()
+			if ts.wantErr != "" {
+				if err == nil || err.Error() != ts.wantErr {
+					t.Fatalf("got error %v, want %q", err, ts.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != ts.wantCount {
+				t.Errorf("got %d selected fields, want %d", count, ts.wantCount)
+			}
+		})
+	}
+}
+
+func TestEnsureFinalNewline(t *testing.T) {
+	// unlike files, a formatted expression doesn't end with a newline
+	node := ast.NewIdent("foo")
+
+	for _, ensure := range []bool{false, true} {
+		cfg := &config{
+			fileSet:            token.NewFileSet(),
+			ensureFinalNewline: ensure,
+		}
+
+		out, err := cfg.format(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.HasSuffix(out, "\n"); got != ensure {
+			t.Errorf("ensureFinalNewline=%t: output %q", ensure, out)
+		}
+	}
+}
+
+func TestLSPPositionSelection(t *testing.T) {
+	test := []struct {
+		position  string
+		wantStart int
+		wantEnd   int
+		wantErr   string
+	}{
+		{position: "3:1", wantStart: 4, wantEnd: 4},
+		{position: "3:8", wantStart: 4, wantEnd: 4},
+		{position: "5:3", wantStart: 6, wantEnd: 8},
+		{position: "6:2", wantStart: 7, wantEnd: 7},
+		{position: "2:0", wantErr: "no struct field at LSP position 2:0"},
+		{position: "3:30", wantErr: "LSP position 3:30 is past the end of its line"},
+		{position: "40:0", wantErr: "LSP position 40:0 is outside of the file"},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.position, func(t *testing.T) {
+			cfg := &config{
+				file:        filepath.Join(fixtureDir, "lsp_position.input"),
+				lspPosition: ts.position,
+			}
+
+			node, err := cfg.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start, end, err := cfg.findSelection(node)
+			if ts.wantErr != "" {
+				if err == nil || err.Error() != ts.wantErr {
+					t.Fatalf("got error %v, want %q", err, ts.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if start != ts.wantStart || end != ts.wantEnd {
+				t.Errorf("got lines %d-%d, want %d-%d", start, end, ts.wantStart, ts.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLSPPositionUTF16(t *testing.T) {
+	// the characters count UTF-16 code units, the emoji is two of them
+	test := []struct {
+		position string
+		wantErr  string
+	}{
+		{position: "3:11"},
+		{position: "3:21"},
+		{position: "3:23", wantErr: "LSP position 3:23 is past the end of its line"},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.position, func(t *testing.T) {
+			cfg := &config{
+				file:        filepath.Join(fixtureDir, "lsp_position_utf16.input"),
+				lspPosition: ts.position,
+			}
+
+			node, err := cfg.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start, end, err := cfg.findSelection(node)
+			if ts.wantErr != "" {
+				if err == nil || err.Error() != ts.wantErr {
+					t.Fatalf("got error %v, want %q", err, ts.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if start != 4 || end != 4 {
+				t.Errorf("got lines %d-%d, want 4-4", start, end)
+			}
+		})
+	}
+}
+
+func TestOffsetSelection(t *testing.T) {
+	test := []struct {
+		offset    string
+		wantStart int
+		wantEnd   int
+		wantErr   string
+	}{
+		{offset: "32", wantStart: 4, wantEnd: 4},
+		{offset: "36", wantStart: 4, wantEnd: 4},
+		{offset: "52", wantStart: 6, wantEnd: 8},
+		{offset: "65", wantStart: 7, wantEnd: 7},
+		{offset: "0", wantErr: "no struct field at offset 0"},
+		{offset: "1000", wantErr: "wrong offset. it must be between 0 and 79"},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.offset, func(t *testing.T) {
+			cfg := &config{
+				file:   filepath.Join(fixtureDir, "lsp_position.input"),
+				offset: ts.offset,
+			}
+
+			node, err := cfg.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start, end, err := cfg.findSelection(node)
+			if ts.wantErr != "" {
+				if err == nil || err.Error() != ts.wantErr {
+					t.Fatalf("got error %v, want %q", err, ts.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if start != ts.wantStart || end != ts.wantEnd {
+				t.Errorf("got lines %d-%d, want %d-%d", start, end, ts.wantStart, ts.wantEnd)
+			}
+		})
+	}
+
+	for _, cfg := range []*config{
+		{file: "foo.go", offset: "32", line: "4"},
+		{file: "foo.go", offset: "32", structName: "foo"},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected an error for -offset with -line %q and -struct %q", cfg.line, cfg.structName)
+		}
+	}
+}
+
+func TestLineRangesErrors(t *testing.T) {
+	test := []struct {
+		line    string
+		wantErr string
+	}{
+		{line: "4;x", wantErr: `wrong line range "x": strconv.Atoi: parsing "x": invalid syntax`},
+		{line: "4,6;9,7", wantErr: `wrong line range "9,7": wrong range. start line cannot be larger than end line`},
+		{line: "4;", wantErr: `wrong line range "": strconv.Atoi: parsing "": invalid syntax`},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.line, func(t *testing.T) {
+			cfg := &config{line: ts.line}
+			if _, _, err := cfg.lineSelection(nil); err == nil || err.Error() != ts.wantErr {
+				t.Errorf("got error %v, want %q", err, ts.wantErr)
+			}
+		})
+	}
+}
+
+func TestStructPatterns(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "struct_glob.input"),
+		structName: "*",
+		fieldName:  "Owner",
+	}
+
+	node, err := cfg.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only UserProfile has the field
+	start, end, err := cfg.findSelection(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 13 || end != 15 {
+		t.Errorf("got lines %d-%d, want 13-15", start, end)
+	}
+
+	cfg.fieldName = "Missing"
+	want := `none of the structs matching "*" has field name "Missing"`
+	if _, _, err := cfg.findSelection(node); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	cfg = &config{file: "foo.go", structName: "User,[a-"}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestFormatOnlyValidation(t *testing.T) {
+	for _, cfg := range []*config{
+		{file: "foo.go", formatOnly: true, from: "int", to: "int64"},
+		{dir: ".", formatOnly: true},
+		{file: "foo.go", formatOnly: true, line: "2"},
+		{file: "foo.go", formatOnly: true, all: true},
+		{file: "foo.go", formatOnly: true, structName: "foo", fieldName: "bar"},
+		{file: stdinFile, formatOnly: true, write: true},
+		{file: "foo.go", formatOnly: true, color: "sometimes"},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestDeclScopeValidation(t *testing.T) {
+	for _, cfg := range []*config{
+		{file: "foo.go", structName: "foo", scope: scopeFuncs, from: "int", to: "int64"},
+		{file: "foo.go", structName: "foo", scope: scopeVars, from: "int", to: "int64"},
+		{file: "foo.go", all: true, scope: scopeVars, ruleSrc: `field.Type == "int" => "int64"`},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestSyncFromAmbiguous(t *testing.T) {
+	reference := filepath.Join(fixtureDir, "sync_ambiguous.reference")
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "sync_from.input"),
+		structName: "User",
+		syncFrom:   reference,
+	}
+
+	want := reference + `: struct name "User" is ambiguous, it's declared at lines 3, 8`
+	if err := cfg.validate(); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestFieldPatternsNotFound(t *testing.T) {
+	test := []struct {
+		field   string
+		wantErr string
+	}{
+		{field: "Missing", wantErr: `struct "foo" doesn't have field name "Missing"`},
+		{field: "Missing,Deleted*", wantErr: `struct "foo" doesn't have field names "Missing", "Deleted*"`},
+		{field: "ID,Typo", wantErr: `struct "foo" doesn't have field name "Typo"`},
+		{field: "ID,Typo,Deleted*", wantErr: `struct "foo" doesn't have field name "Typo"`},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.field, func(t *testing.T) {
+			cfg := &config{
+				file:       filepath.Join(fixtureDir, "field_patterns.input"),
+				structName: "foo",
+				fieldName:  ts.field,
+			}
+
+			node, err := cfg.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := cfg.findSelection(node); err == nil || err.Error() != ts.wantErr {
+				t.Errorf("got error %v, want %q", err, ts.wantErr)
+			}
+		})
+	}
+}
+
+func TestAmbiguousStruct(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "ambiguous_struct.input"),
+		structName: "Foo",
+		from:       "int",
+		to:         "int64",
+	}
+
+	_, err := cfg.process()
+	want := `struct name "Foo" is ambiguous, it's declared at lines 4, 11. pass -all-matches to process all of them`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+
+	// it's reported in -dir mode too, unlike a missing struct
+	var selErr *selectionError
+	if errors.As(err, &selErr) {
+		t.Error("got a selection error, which is skipped in -dir mode")
+	}
+}
+
+func TestMultipleSelectors(t *testing.T) {
+	test := []struct {
+		name      string
+		cfg       *config
+		wantStart int
+		wantEnd   int
+		wantErr   string
+	}{
+		{
+			name:      "line and all",
+			cfg:       &config{line: "4,5", all: true},
+			wantStart: 4,
+			wantEnd:   5,
+		},
+		{
+			name:      "line and struct",
+			cfg:       &config{line: "6,12", structName: "foo"},
+			wantStart: 6,
+			wantEnd:   8,
+		},
+		{
+			name:      "struct and offset",
+			cfg:       &config{structName: "foo", offset: "40"},
+			wantStart: 5,
+			wantEnd:   5,
+		},
+		{
+			name:      "struct index and only lines",
+			cfg:       &config{structIndex: 2, onlyLines: "4,11"},
+			wantStart: 10,
+			wantEnd:   11,
+		},
+		{
+			name:    "disjoint line and struct",
+			cfg:     &config{line: "4,5", structName: "bar"},
+			wantErr: "the selections don't have any line in common",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			cfg := ts.cfg
+			cfg.file = filepath.Join(fixtureDir, "multiple_selectors.input")
+			cfg.multipleSelectors = true
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := cfg.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start, end, err := cfg.findSelection(node)
+			if ts.wantErr != "" {
+				if err == nil || err.Error() != ts.wantErr {
+					t.Fatalf("got error %v, want %q", err, ts.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if start != ts.wantStart || end != ts.wantEnd {
+				t.Errorf("got lines %d-%d, want %d-%d", start, end, ts.wantStart, ts.wantEnd)
+			}
+
+			// the combination is rejected without -allow-multiple-selectors
+			cfg.multipleSelectors = false
+			if cfg.all {
+				return
+			}
+			if err := cfg.validate(); err == nil {
+				t.Error("expected an error without -allow-multiple-selectors")
+			}
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:        filepath.Join(fixtureDir, "field_type_modify.input"),
+		all:         true,
+		from:        "string",
+		to:          "[]byte",
+		traceStages: true,
+		stderr:      &stderr,
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	stages := []string{"parse", "select", "rewrite", "format"}
+	if len(lines) != len(stages) {
+		t.Fatalf("expected %d trace lines, got:\n%s", len(stages), stderr.String())
+	}
+	for i, stage := range stages {
+		if !strings.HasPrefix(lines[i], "trace: "+stage+" took ") {
+			t.Errorf("unexpected trace line %q for stage %s", lines[i], stage)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	// don't output help message during the test
+	flag.CommandLine.SetOutput(ioutil.Discard)
+
+	// The flag.CommandLine.Parse() call fails if there are flags re-defined
+	// with the same name. If there are duplicates, parseConfig() will return
+	// an error.
+	_, err := parseConfig([]string{"test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureParses(t *testing.T) {
+	for _, ensure := range []bool{false, true} {
+		cfg := &config{
+			file:         filepath.Join(fixtureDir, "field_type_modify.input"),
+			structName:   "foo",
+			fieldName:    "bar",
+			from:         "string",
+			to:           "map[string",
+			ensureParses: ensure,
+		}
+
+		_, err := cfg.process()
+		if ensure {
+			if err == nil || !strings.HasPrefix(err.Error(), "rewritten file doesn't parse: ") {
+				t.Errorf("ensureParses=%t: got error %v", ensure, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ensureParses=%t: %s", ensure, err)
+		}
+	}
+}
+
+func TestMultilineFieldPositions(t *testing.T) {
+	cfg := &config{
+		file:  filepath.Join(fixtureDir, "multiline_comments.input"),
+		all:   true,
+		from:  "func(ctx string) error",
+		to:    "HandlerFunc",
+		froms: []string{"func(ctx string) error", "string"},
+		tos:   []string{"HandlerFunc", "[]byte"},
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.changes) != 2 || cfg.changes[0].Line != 4 || cfg.changes[1].Line != 8 {
+		t.Fatalf("got changes %+v, want them at lines 4 and 8", cfg.changes)
+	}
+
+	// the lines of the file are left as is by the multi-line replacement, so
+	// positions are still looked up at their lines afterwards
+	tokFile := cfg.fileSet.File(token.Pos(1))
+	for _, ch := range cfg.changes {
+		if line := tokFile.Line(tokFile.Pos(ch.Offset)); line != ch.Line {
+			t.Errorf("offset %d of %s is looked up at line %d, want %d", ch.Offset, ch.Field, line, ch.Line)
+		}
+	}
+}
+
+func TestPrintImports(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:         filepath.Join(fixtureDir, "tidy_imports.input"),
+		structName:   "foo",
+		from:         "time.Duration",
+		to:           "strings.Builder",
+		tidyImports:  true,
+		printImports: true,
+		stderr:       &stderr,
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "+ \"strings\"\n- \"time\"\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	// undoing field_type_modify gives its input back
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_type_modify.golden"),
+		structName: "foo",
+		fieldName:  "bar",
+		from:       "string",
+		to:         "[]byte",
+		reverse:    true,
+	}
+
+	// validating again doesn't undo -reverse
+	for i := 0; i < 2; i++ {
+		if err := cfg.validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ioutil.ReadFile(filepath.Join(fixtureDir, "field_type_modify.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestReverseConflict(t *testing.T) {
+	cfg := &config{
+		file:    filepath.Join(fixtureDir, "multiple_pairs.input"),
+		all:     true,
+		from:    "int",
+		to:      "int64",
+		froms:   []string{"int", "int32"},
+		tos:     []string{"int64", "int64"},
+		reverse: true,
+	}
+
+	want := "-reverse is conflicting: int64 would be changed to both int and int32"
+	if err := cfg.validate(); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	// the pairs can be reversed once they don't share a -to
+	cfg.tos = []string{"int64", "int16"}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+}
+
+func TestNormalizeTo(t *testing.T) {
+	test := []struct {
+		to   string
+		want string
+	}{
+		{to: "[ ]byte", want: "[]byte"},
+		{to: "* pkg . T", want: "*pkg.T"},
+		{to: "map[ string ]  []int", want: "map[string][]int"},
+		{to: "func(a,b int)(error)", want: "func(a, b int) error"},
+		{to: "chan<-  struct{ }", want: "chan<- struct{}"},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.to, func(t *testing.T) {
+			cfg := &config{
+				file:        filepath.Join(fixtureDir, "field_type_modify.input"),
+				structName:  "foo",
+				fieldName:   "bar",
+				from:        "string",
+				to:          ts.to,
+				normalizeTo: true,
+			}
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cfg.changes) != 1 || cfg.changes[0].To != ts.want {
+				t.Errorf("got changes %+v, want a single change to %q", cfg.changes, ts.want)
+			}
+
+			if cfg.to != ts.to {
+				t.Errorf("-to is changed to %q by the validation", cfg.to)
+			}
+		})
+	}
+
+	cfg := &config{
+		file:        filepath.Join(fixtureDir, "field_type_modify.input"),
+		all:         true,
+		from:        "string",
+		to:          "map[string",
+		normalizeTo: true,
+	}
+	if err := cfg.validate(); err == nil || !strings.HasPrefix(err.Error(), "invalid -to: ") {
+		t.Errorf("got error %v for a broken -to", err)
+	}
+}
+
+func TestParsedTarget(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_stride.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "map[string]func() (int, error)",
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := cfg.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start, end, err := cfg.findSelection(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.rewrite(node, start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	st := cfg.lookupStruct(node, "foo")
+	if st == nil {
+		t.Fatal("struct foo not found")
+	}
+
+	seen := make(map[ast.Expr]bool)
+	for _, f := range st.Fields.List {
+		m, ok := f.Type.(*ast.MapType)
+		if !ok {
+			t.Fatalf("field %s: got %T, want *ast.MapType", f.Names[0], f.Type)
+		}
+		if seen[m] {
+			t.Errorf("field %s shares its type node with another field", f.Names[0])
+		}
+		seen[m] = true
+
+		if line := cfg.fileSet.Position(m.Pos()).Line; line != cfg.fileSet.Position(f.Pos()).Line {
+			t.Errorf("field %s: type moved to line %d", f.Names[0], line)
+		}
+	}
+
+	out, err := cfg.format(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "\tKey0   map[string]func() (int, error)\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	cfg = &config{
+		file: filepath.Join(fixtureDir, "field_stride.input"),
+		all:  true,
+		from: "string",
+		to:   "map[string",
+	}
+	if err := cfg.validate(); err == nil || !strings.HasPrefix(err.Error(), "invalid -to: ") {
+		t.Errorf("got error %v for a broken -to", err)
+	}
+}
+
+func TestUnexportedTarget(t *testing.T) {
+	test := []struct {
+		to   string
+		name string
+	}{
+		{to: "pb.message", name: "pb.message"},
+		{to: "map[string]*pb.message", name: "pb.message"},
+		{to: "pb.List[pb.item]", name: "pb.item"},
+	}
+
+	for _, ts := range test {
+		cfg := &config{
+			file:       filepath.Join(fixtureDir, "unexported_target.input"),
+			structName: "foo",
+			from:       "string",
+			to:         ts.to,
+		}
+
+		want := "invalid -to: " + ts.name + " is not exported, it can't be used outside of its package"
+		if err := cfg.validate(); err == nil || err.Error() != want {
+			t.Errorf("got error %v for %q, want %q", err, ts.to, want)
+		}
+	}
+
+	// templates are checked for each field, the unexported expansion is
+	// reported and the field is kept
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "unexported_target.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "pb.$NAME",
+		stderr:     &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.changes) != 1 || cfg.changes[0].To != "pb.Status" {
+		t.Errorf("got changes %+v, want a single change to pb.Status", cfg.changes)
+	}
+
+	want := "test-fixtures/unexported_target.input:5:2: warning: \"pb.$NAME\" expands to \"pb.code\" for code, pb.code is not exported\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExprEqual(t *testing.T) {
+	test := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "map[string] int", b: "map[string]int", want: true},
+		{a: "[ ]byte", b: "[]uint8", want: true},
+		{a: "* pkg . T", b: "*pkg.T", want: true},
+		{a: "[4]rune", b: "[4]int32", want: true},
+		{a: "(string)", b: "string", want: true},
+		{a: "chan<- int", b: "chan<-int", want: true},
+		{a: "func(a int) error", b: "func(a  int)  error", want: true},
+		{a: "map[string]int", b: "map[string]int64", want: false},
+		{a: "[4]int", b: "[5]int", want: false},
+		{a: "[]int", b: "[4]int", want: false},
+		{a: "chan int", b: "<-chan int", want: false},
+		{a: "pkg.T", b: "other.T", want: false},
+		{a: "*T", b: "T", want: false},
+	}
+
+	for _, ts := range test {
+		a, err := parser.ParseExpr(ts.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parser.ParseExpr(ts.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := exprEqual(a, b); got != ts.want {
+			t.Errorf("exprEqual(%q, %q) = %t, want %t", ts.a, ts.b, got, ts.want)
+		}
+	}
+}
+
+func TestMatchFromStructurally(t *testing.T) {
+	cfg := &config{
+		file: filepath.Join(fixtureDir, "field_stride.input"),
+		all:  true,
+		from: "string ",
+		to:   "[]byte",
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.changes) != 5 {
+		t.Errorf("expected the 5 string fields to match, got %d changes", len(cfg.changes))
+	}
+
+	// an invalid -from is compared as is
+	cfg.from = "map[string"
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.changes) != 0 {
+		t.Errorf("expected no changes, got %v", cfg.changes)
+	}
+}
+
+func TestToTemplate(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_stride.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "Typed$NAME",
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ch := range cfg.changes {
+		got = append(got, ch.To)
+	}
+	want := "TypedKey0 TypedValue0 TypedKey1 TypedValue1 TypedKey2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got targets %v, want %s", got, want)
+	}
+}
+
+func TestParseConfigEnv(t *testing.T) {
+	defer func(commandLine *flag.FlagSet) { flag.CommandLine = commandLine }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("gomodifytype", flag.ContinueOnError)
+	flag.CommandLine.SetOutput(ioutil.Discard)
+
+	t.Setenv("GOMODIFYTYPE_FROM", "int")
+	t.Setenv("GOMODIFYTYPE_TO", "int32")
+	t.Setenv("GOMODIFYTYPE_ALL", "true")
+	t.Setenv("GOMODIFYTYPE_SKIP_UNEXPORTED", "true")
+
+	cfg, err := parseConfig([]string{"-file", "foo.go", "-to", "int64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.file != "foo.go" || cfg.from != "int" || !cfg.all || !cfg.skipUnexportedFields {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	// the command line takes precedence
+	if cfg.to != "int64" {
+		t.Errorf("got -to %q, want int64", cfg.to)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	src := "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+	cfg := &config{
+		file:          stdinFile,
+		stdinFilename: "buffer.go",
+		all:           true,
+		from:          "string",
+		to:            "[]byte",
+		stdin:         strings.NewReader(src),
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "package foo\n\ntype foo struct {\n\tbar []byte\n}\n"
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	if len(cfg.changes) != 1 || cfg.changes[0].File != "buffer.go" {
+		t.Errorf("expected a change in buffer.go, got %+v", cfg.changes)
+	}
+
+	cfg.write = true
+	if err := cfg.validate(); err == nil {
+		t.Error("expected -w to be rejected when reading from stdin")
+	}
+}
+
+func TestMultiplePairsValidation(t *testing.T) {
+	cfg := &config{
+		file:  filepath.Join(fixtureDir, "multiple_pairs.input"),
+		all:   true,
+		from:  "int",
+		to:    "int64",
+		froms: []string{"int", "string"},
+		tos:   []string{"int64"},
+	}
+
+	want := "-from is passed 2 times and -to 1 times, they should be paired"
+	if err := cfg.validate(); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	cfg = &config{
+		file:  filepath.Join(fixtureDir, "multiple_pairs.input"),
+		all:   true,
+		from:  "int",
+		froms: []string{"int"},
+	}
+
+	want = "-from is requiring -to"
+	if err := cfg.validate(); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestNolintDirectives(t *testing.T) {
+	cfg := &config{
+		file:  filepath.Join(fixtureDir, "nolint_directive.input"),
+		all:   true,
+		from:  "string",
+		to:    "[]byte",
+		froms: []string{"string", "func(ctx string) error"},
+		tos:   []string{"[]byte", "HandlerFunc"},
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each directive is kept once, on the line of its field
+	for _, line := range []string{
+		"\tName    []byte      //nolint:revive // keep the legacy name\n",
+		"\tAliases []byte      /* nolint */\n",
+		"\tHandler HandlerFunc //nolint:lll\n",
+		"\tOther   int         //nolint\n",
+	} {
+		if strings.Count(out, line) != 1 {
+			t.Errorf("expected the line %q once in:\n%s", line, out)
+		}
+	}
+	if n := strings.Count(out, "nolint"); n != 4 {
+		t.Errorf("expected 4 directives, got %d in:\n%s", n, out)
+	}
+}
+
+func TestDeepOff(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "deep.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "[]byte",
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.changes) != 1 || cfg.changes[0].Field != "Plain" {
+		t.Errorf("got changes %+v, want only Plain to be changed without -deep", cfg.changes)
+	}
+}
+
+func TestTestTablesValidation(t *testing.T) {
+	// only test files have test tables
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "test_tables.input"),
+		all:        true,
+		from:       "string",
+		to:         "[]byte",
+		testTables: true,
+	}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected -test-tables to be rejected for a non-test file")
+	}
+}
+
+func TestListStructs(t *testing.T) {
+	cfg := &config{
+		file: filepath.Join(fixtureDir, "list.input"),
+		list: true,
+	}
+
+	var buf bytes.Buffer
+	if err := cfg.listStructs(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `test-fixtures/list.input:5: foo
+	A string
+	B string
+	Buffer *bytes.Buffer
+	Nested struct{C []int}
+test-fixtures/list.input:8: Nested
+	C []int
+test-fixtures/list.input:13: bar
+	M map[string]struct{}
+test-fixtures/list.input:18: <anonymous>
+	in string
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if cfg.changes != nil {
+		t.Errorf("expected no changes, got %+v", cfg.changes)
+	}
+}
@@ -0,0 +1,189 @@
+package gomodifytype
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"os"
+	"path"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// importChange is an import added or removed by the rewrite.
+type importChange struct {
+	added bool
+	name  string
+	path  string
+}
+
+// String returns the import in the -print-imports form, i.e: + "time" or
+// - old "github.com/x/old".
+func (ch importChange) String() string {
+	op := "-"
+	if ch.added {
+		op = "+"
+	}
+	if ch.name != "" {
+		return fmt.Sprintf("%s %s %q", op, ch.name, ch.path)
+	}
+	return fmt.Sprintf("%s %q", op, ch.path)
+}
+
+// printImportChanges prints the added and removed imports to stderr, one per
+// line, so they're reviewed apart from the field changes.
+func (c *config) printImportChanges() {
+	stderr := c.stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	for _, ch := range c.importChanges {
+		_, _ = fmt.Fprintln(stderr, ch)
+	}
+}
+
+// addImports imports the packages qualifying the types the fields are changed
+// to, i.e: time for time.Duration. Standard library packages are resolved by
+// their name, others need -import-path and are skipped without it. Packages
+// the file already imports under the same name are left alone.
+func (c *config) addImports(file *ast.File) {
+	var targets []ast.Expr
+	for _, ch := range c.changes {
+		if expr, err := c.parseTarget(ch.To); err == nil {
+			targets = append(targets, expr)
+		}
+	}
+
+	qualifiers := packageNames(targets)
+	for _, name := range qualifiers {
+		if importsName(file, name) {
+			continue
+		}
+
+		// unknown packages are left to goimports
+		importPath := c.qualifierPath(name, len(qualifiers))
+		if importPath == "" {
+			continue
+		}
+
+		if path.Base(importPath) == name {
+			if astutil.AddImport(c.fileSet, file, importPath) {
+				c.importChanges = append(c.importChanges, importChange{added: true, path: importPath})
+			}
+		} else if astutil.AddNamedImport(c.fileSet, file, name, importPath) {
+			c.importChanges = append(c.importChanges, importChange{added: true, name: name, path: importPath})
+		}
+	}
+}
+
+// qualifierPath returns the import path of the package named name. The
+// -import-path is used if it's the only package, or if it's named after the
+// last element of the path. Otherwise, standard library packages are looked
+// up.
+func (c *config) qualifierPath(name string, packages int) string {
+	if c.importPath != "" && (packages == 1 || path.Base(c.importPath) == name) {
+		return c.importPath
+	}
+
+	pkg, err := build.Default.Import(name, "", 0)
+	if err != nil || !pkg.Goroot || pkg.Name != name {
+		return ""
+	}
+	return pkg.ImportPath
+}
+
+// importsName reports whether the file imports a package under the name,
+// either explicitly or by the last element of its path.
+func importsName(file *ast.File, name string) bool {
+	return importSpec(file, name) != nil
+}
+
+// importSpec returns the import of the package with the name, either explicit
+// or the last element of its path, or nil if there is no such import.
+func importSpec(file *ast.File, name string) *ast.ImportSpec {
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			if spec.Name.Name == name {
+				return spec
+			}
+			continue
+		}
+
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err == nil && path.Base(importPath) == name {
+			return spec
+		}
+	}
+	return nil
+}
+
+// removeUnusedImports removes the imports of the packages qualifying -from
+// types, i.e: time for time.Duration, if the file doesn't refer to them
+// anymore after the rewrite.
+func (c *config) removeUnusedImports(file *ast.File) {
+	var froms []ast.Expr
+	for _, pair := range c.typePairs() {
+		if expr, err := parser.ParseExpr(pair.from); err == nil {
+			froms = append(froms, expr)
+		}
+	}
+
+	for _, name := range packageNames(froms) {
+		spec := importSpec(file, name)
+		if spec == nil || usesPackage(file, name) {
+			continue
+		}
+
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if spec.Name != nil {
+			if astutil.DeleteNamedImport(c.fileSet, file, spec.Name.Name, importPath) {
+				c.importChanges = append(c.importChanges, importChange{name: spec.Name.Name, path: importPath})
+			}
+		} else if astutil.DeleteImport(c.fileSet, file, importPath) {
+			c.importChanges = append(c.importChanges, importChange{path: importPath})
+		}
+	}
+}
+
+// packageNames returns the names of the packages qualifying the type
+// expressions, in order.
+func packageNames(exprs []ast.Expr) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if ident, ok := sel.X.(*ast.Ident); ok && !seen[ident.Name] {
+				seen[ident.Name] = true
+				names = append(names, ident.Name)
+			}
+			return false
+		})
+	}
+	return names
+}
+
+// usesPackage reports whether any selector of the file is qualified by the
+// name, i.e: time.Now() in a function body. Local variables shadowing the
+// package count too, so the import is rather kept than removed wrongly.
+func usesPackage(file *ast.File, name string) bool {
+	used := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == name {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
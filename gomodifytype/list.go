@@ -0,0 +1,63 @@
+package gomodifytype
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"sort"
+)
+
+// listStructs writes the structs of the file in source order, each with its
+// file and line followed by its fields and their types, one per line, i.e:
+//
+//	foo.go:3: foo
+//		Bar string
+//		Baz []int
+//
+// The types are printed the way -from is matched against them.
+func (c *config) listStructs(w io.Writer) error {
+	if c.file == "" {
+		return errors.New("no file is passed")
+	}
+
+	node, err := c.parse()
+	if err != nil {
+		return err
+	}
+
+	var structs []*structType
+	for _, st := range collectStructs(node, true) {
+		structs = append(structs, st)
+	}
+	sort.Slice(structs, func(i, j int) bool { return structs[i].node.Pos() < structs[j].node.Pos() })
+
+	for _, st := range structs {
+		name := st.name
+		if name == "" {
+			name = anonymousStruct
+		}
+		pos := c.fileSet.Position(st.node.Pos())
+		if _, err := fmt.Fprintf(w, "%s:%d: %s\n", pos.Filename, pos.Line, name); err != nil {
+			return err
+		}
+
+		for _, f := range st.node.Fields.List {
+			t := types.ExprString(f.Type)
+			var names []string
+			for _, ident := range f.Names {
+				names = append(names, ident.Name)
+			}
+			if f.Names == nil {
+				names = append(names, embeddedName(f.Type))
+			}
+
+			for _, fieldName := range names {
+				if _, err := fmt.Fprintf(w, "\t%s %s\n", fieldName, t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
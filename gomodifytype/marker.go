@@ -0,0 +1,45 @@
+package gomodifytype
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// isLineComment reports whether s is a single // comment.
+func isLineComment(s string) bool {
+	return strings.HasPrefix(s, "//") && !strings.Contains(s, "\n")
+}
+
+// hasMarker reports whether the file has a comment matching the -mark-done
+// marker, ignoring surrounding white space.
+func hasMarker(file *ast.File, marker string) bool {
+	marker = strings.TrimSpace(marker)
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.TrimSpace(comment.Text) == marker {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insertMarker inserts the marker comment on its own line after the package
+// clause of the formatted source, so it isn't taken for the package doc.
+func insertMarker(src []byte, marker string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := fset.Position(file.Name.End()).Offset
+
+	var out []byte
+	out = append(out, src[:offset]...)
+	out = append(out, "\n\n"+strings.TrimSpace(marker)...)
+	out = append(out, src[offset:]...)
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSkipIfMarked(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:         filepath.Join(fixtureDir, "skip_if_marked.input"),
+		all:          true,
+		from:         "int64",
+		to:           "int32",
+		markDone:     "// migrated:v2",
+		skipIfMarked: true,
+		stderr:       &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ioutil.ReadFile(cfg.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != string(src) || len(cfg.changes) != 0 {
+		t.Errorf("expected the marked file to be skipped, got changes %+v and:\n%s", cfg.changes, out)
+	}
+
+	if !strings.Contains(stderr.String(), "skipped") {
+		t.Errorf("expected the skipped file to be reported, got %q", stderr.String())
+	}
+}
+
+func TestMarkDoneOnce(t *testing.T) {
+	cfg := &config{
+		file:     filepath.Join(fixtureDir, "skip_if_marked.input"),
+		all:      true,
+		from:     "int64",
+		to:       "int32",
+		markDone: "// migrated:v2",
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(out, "// migrated:v2"); n != 1 {
+		t.Errorf("got the marker %d times, want once:\n%s", n, out)
+	}
+}
+
+func TestMarkDoneValidation(t *testing.T) {
+	test := []struct {
+		name string
+		cfg  *config
+	}{
+		{
+			name: "not a comment",
+			cfg:  &config{markDone: "migrated:v2"},
+		},
+		{
+			name: "block comment",
+			cfg:  &config{markDone: "/* migrated:v2 */"},
+		},
+		{
+			name: "skip without marker",
+			cfg:  &config{skipIfMarked: true},
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			ts.cfg.file = filepath.Join(fixtureDir, "mark_done.input")
+			ts.cfg.all = true
+			ts.cfg.from = "int"
+			ts.cfg.to = "int64"
+
+			if err := ts.cfg.validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"unicode"
+	"unicode/utf8"
+)
+
+// writeMigration writes a function stub for each struct with changed fields,
+// converting a value of the old declaration of the struct to the new one.
+// The fields which changed type are assigned, so the compiler points at the
+// conversions to be filled in, i.e:
+//
+//	// migrateUser converts a value of the old declaration of User, OldUser,
+//	// to the new one.
+//	func migrateUser(old OldUser) User {
+//		var migrated User
+//		migrated.ID = old.ID // TODO: convert int to int64
+//		return migrated
+//	}
+//
+// The old declaration is to be copied by hand, named OldUser for User and
+// oldUser for user. Anonymous structs are skipped, they can't be named in a
+// signature.
+func writeMigration(w io.Writer, pkg string, changes []change) error {
+	var structs []string
+	fields := make(map[string][]change)
+	for _, ch := range changes {
+		if ch.Struct == "" {
+			continue
+		}
+		if _, ok := fields[ch.Struct]; !ok {
+			structs = append(structs, ch.Struct)
+		}
+		fields[ch.Struct] = append(fields[ch.Struct], ch)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Migration stubs generated by gomodifytype, the conversions of the\n")
+	fmt.Fprintf(&buf, "// fields which changed type are to be filled in.\n\n")
+	fmt.Fprintf(&buf, "package %s\n", pkg)
+
+	for _, name := range structs {
+		fn, old := "migrate"+upperFirst(name), "Old"+upperFirst(name)
+		if !isPublicName(name) {
+			old = "old" + upperFirst(name)
+		}
+
+		fmt.Fprintf(&buf, "\n// %s converts a value of the old declaration of %s, %s,\n// to the new one.\n", fn, name, old)
+		fmt.Fprintf(&buf, "func %s(old %s) %s {\n", fn, old, name)
+		fmt.Fprintf(&buf, "var migrated %s\n", name)
+		for _, ch := range fields[name] {
+			fmt.Fprintf(&buf, "migrated.%s = old.%s // TODO: convert %s to %s\n", ch.Field, ch.Field, ch.From, ch.To)
+		}
+		fmt.Fprintf(&buf, "return migrated\n}\n")
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// upperFirst returns the name with its first letter in upper case.
+func upperFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// emitMigration writes the migration stubs of the changes to the
+// -emit-migration file, in the package of the processed file.
+func (c *config) emitMigration() error {
+	file, err := parser.ParseFile(token.NewFileSet(), c.filename(), c.src, parser.PackageClauseOnly)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeMigration(&buf, file.Name.Name, c.changes); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.migrationFile, buf.Bytes(), 0644)
+}
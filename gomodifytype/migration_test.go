@@ -0,0 +1,105 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteMigration(t *testing.T) {
+	changes := []change{
+		{Struct: "User", Field: "ID", From: "int", To: "int64"},
+		{Struct: "account", Field: "Balance", From: "float64", To: "decimal.Decimal"},
+		{Struct: "User", Field: "Tags", From: "[]string", To: "Tags"},
+		{Field: "N", From: "int", To: "int64"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeMigration(&buf, "foo", changes); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `// Migration stubs generated by gomodifytype, the conversions of the
+// fields which changed type are to be filled in.
+
+package foo
+
+// migrateUser converts a value of the old declaration of User, OldUser,
+// to the new one.
+func migrateUser(old OldUser) User {
+	var migrated User
+	migrated.ID = old.ID     // TODO: convert int to int64
+	migrated.Tags = old.Tags // TODO: convert []string to Tags
+	return migrated
+}
+
+// migrateAccount converts a value of the old declaration of account, oldAccount,
+// to the new one.
+func migrateAccount(old oldAccount) account {
+	var migrated account
+	migrated.Balance = old.Balance // TODO: convert float64 to decimal.Decimal
+	return migrated
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEmitMigration(t *testing.T) {
+	tests := []struct {
+		structName string
+		want       []string
+	}{
+		{structName: "Account", want: []string{"migrated.ID = old.ID // TODO: convert int to int64\n"}},
+		{
+			// each name of the Age, Rank group gets a stub
+			structName: "User",
+			want: []string{
+				"migrated.ID = old.ID     // TODO: convert int to int64\n",
+				"migrated.Age = old.Age   // TODO: convert int to int64\n",
+				"migrated.Rank = old.Rank // TODO: convert int to int64\n",
+			},
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.structName, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "migrations.go")
+			cfg := &config{
+				file:          filepath.Join(fixtureDir, "sync_from.input"),
+				structName:    ts.structName,
+				from:          "int",
+				to:            "int64",
+				migrationFile: file,
+			}
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cfg.emitMigration(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Contains(got, []byte("package foo\n")) {
+				t.Errorf("got:\n%s\nwant the foo package", got)
+			}
+			for _, want := range ts.want {
+				if !bytes.Contains(got, []byte(want)) {
+					t.Errorf("got:\n%s\nwant %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package gomodifytype
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// emitPair writes the original and the rewritten content of a changed file
+// to the -emit-pairs directory, as name.before and name.after, i.e. to seed
+// golden tests. The name is the path of the file relative to -dir, or its
+// base name. Files without changes are skipped.
+func (c *config) emitPair(name, out string) error {
+	if c.pairsDir == "" || len(c.changes) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(c.pairsDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path+".before", c.src, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".after", []byte(out), 0644)
+}
@@ -0,0 +1,65 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmitPairs(t *testing.T) {
+	const src = "package foo\n\ntype foo struct {\n\tbar string\n}\n"
+	const want = "package foo\n\ntype foo struct {\n\tbar []byte\n}\n"
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":         src,
+		"sub/b.go":     src,
+		"sub/other.go": "package foo\n\ntype foo struct {\n\tn int\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pairs := t.TempDir()
+	cfg := &config{
+		dir:        dir,
+		structName: "foo",
+		from:       "string",
+		to:         "[]byte",
+		pairsDir:   pairs,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := cfg.processDir(&stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.go", "sub/b.go"} {
+		for ext, want := range map[string]string{".before": src, ".after": want} {
+			got, err := ioutil.ReadFile(filepath.Join(pairs, name+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("%s%s: got:\n%s\nwant:\n%s", name, ext, got, want)
+			}
+		}
+	}
+
+	// other.go has no changes
+	if _, err := os.Stat(filepath.Join(pairs, "sub", "other.go.before")); !os.IsNotExist(err) {
+		t.Errorf("got a pair of the unchanged file, error %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package gomodifytype
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Output formats of the result.
+const (
+	// outputText prints the rewritten file
+	outputText = "text"
+	// outputJSON prints the change records as a JSON array, same as -json
+	outputJSON = "json"
+	// outputJSONL streams the change records, one JSON object per line
+	outputJSONL = "jsonl"
+	// outputSARIF prints the change records as a SARIF report
+	outputSARIF = "sarif"
+)
+
+// change describes a single replaced field type. It's printed as is with
+// -json, so the json tags define the format of the change records.
+type change struct {
+	Struct string `json:"struct,omitempty"`
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+
+	// end is the offset the changed field ends at in the original file
+	end int
+}
+
+func (ch change) position() token.Position {
+	return token.Position{
+		Filename: ch.File,
+		Offset:   ch.Offset,
+		Line:     ch.Line,
+		Column:   ch.Column,
+	}
+}
+
+// writeChangesJSON writes the change records as a JSON array.
+func writeChangesJSON(w io.Writer, changes []change) error {
+	if changes == nil {
+		changes = []change{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(changes)
+}
+
+// streamChange writes the change record as a single line of JSON as soon as
+// it's made, so large runs don't have to be buffered.
+func (c *config) streamChange(ch change) {
+	w := c.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	_ = json.NewEncoder(w).Encode(ch)
+}
+
+// sarifLog is the subset of the SARIF 2.1.0 format, as used by code scanning
+// tools, needed to report the change records.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// sarifRuleID identifies the change records in a SARIF report.
+const sarifRuleID = "field-type-change"
+
+// writeChangesSARIF writes the change records as a SARIF report, with a
+// result for each change.
+func writeChangesSARIF(w io.Writer, changes []change) error {
+	results := []sarifResult{}
+	for _, ch := range changes {
+		field := ch.Field
+		if ch.Struct != "" {
+			field = ch.Struct + "." + ch.Field
+		}
+
+		results = append(results, sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   "note",
+			Message: sarifMessage{Text: fmt.Sprintf("%s changed from %s to %s", field, ch.From, ch.To)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(ch.File)},
+					Region:           sarifRegion{StartLine: ch.Line, StartColumn: ch.Column},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "gomodifytype",
+					InformationURI: "https://github.com/FZambia/gomodifytype",
+					Rules: []sarifRule{{
+						ID:               sarifRuleID,
+						ShortDescription: sarifMessage{Text: "Field type change"},
+					}},
+				},
+			},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// writeChangeSchema writes the JSON schema of the -json output. It's derived
+// from the change struct so both can't get out of sync.
+func writeChangeSchema(w io.Writer) error {
+	properties := make(map[string]interface{})
+	var required []string
+
+	t := reflect.TypeOf(change{})
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		typ := "string"
+		switch t.Field(i).Type.Kind() {
+		case reflect.Int:
+			typ = "integer"
+		case reflect.Bool:
+			typ = "boolean"
+		}
+		properties[name] = map[string]string{"type": typ}
+
+		if opts != "omitempty" {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "gomodifytype change records",
+		"type":    "array",
+		"items": map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
+
+// printAffectedTypes prints the distinct types which were replaced and the
+// types they were replaced with, one per line and sorted.
+func (c *config) printAffectedTypes() {
+	from := make(map[string]bool)
+	to := make(map[string]bool)
+	for _, ch := range c.changes {
+		from[ch.From] = true
+		to[ch.To] = true
+	}
+
+	for _, name := range sortedKeys(from) {
+		_, _ = fmt.Fprintf(c.stderr, "from %s\n", name)
+	}
+	for _, name := range sortedKeys(to) {
+		_, _ = fmt.Fprintf(c.stderr, "to %s\n", name)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printAPIBreaks prints a warning for every retyped exported field of an
+// exported struct, as it's a potential API break for the package users.
+func (c *config) printAPIBreaks() {
+	for _, ch := range c.changes {
+		if !isPublicName(ch.Struct) || !isPublicName(ch.Field) {
+			continue
+		}
+		_, _ = fmt.Fprintf(c.stderr, "%s: warning: changing exported field %s.%s from %s to %s is a potential API break\n",
+			ch.position(), ch.Struct, ch.Field, ch.From, ch.To)
+	}
+}
+
+// anonymousStruct names structs without a name in the type graph and the
+// -list output, i.e. struct literals which aren't assigned to a variable.
+const anonymousStruct = "<anonymous>"
+
+// writeTypeGraph writes the fields matching -from of each struct, one struct
+// per line in the order of their first change, i.e:
+//
+//	foo: Bar, Baz
+//	qux: Quux
+func writeTypeGraph(w io.Writer, changes []change) error {
+	var structs []string
+	fields := make(map[string][]string)
+	for _, ch := range changes {
+		name := ch.Struct
+		if name == "" {
+			name = anonymousStruct
+		}
+
+		if _, ok := fields[name]; !ok {
+			structs = append(structs, name)
+		}
+		fields[name] = append(fields[name], ch.Field)
+	}
+
+	for _, name := range structs {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", name, strings.Join(fields[name], ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSummary writes a single line summarizing the changes, i.e:
+//
+//	gomodifytype: changed 7 fields in 3 structs across 2 files (int -> int64)
+func writeSummary(w io.Writer, changes []change) {
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(w, "gomodifytype: no fields changed")
+		return
+	}
+
+	fields := make(map[token.Position]bool)
+	structs := make(map[[2]string]bool)
+	files := make(map[string]bool)
+	seen := make(map[string]bool)
+	var types []string
+	for _, ch := range changes {
+		fields[ch.position()] = true
+		// fields of anonymous structs are counted without a struct
+		if ch.Struct != "" {
+			structs[[2]string{ch.File, ch.Struct}] = true
+		}
+		files[ch.File] = true
+
+		t := ch.From + " -> " + ch.To
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "gomodifytype: changed %d %s in %d %s across %d %s (%s)\n",
+		len(fields), plural(len(fields), "field", "fields"),
+		len(structs), plural(len(structs), "struct", "structs"),
+		len(files), plural(len(files), "file", "files"),
+		strings.Join(types, ", "))
+}
+
+// writePositions writes the byte offset span of each changed field in the
+// original file, one per line, i.e: foo.go:40-52.
+func writePositions(w io.Writer, changes []change) error {
+	for _, ch := range changes {
+		if _, err := fmt.Fprintf(w, "%s:%d-%d\n", ch.File, ch.Offset, ch.end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,554 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAffectedTypes(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:          filepath.Join(fixtureDir, "rule.input"),
+		all:           true,
+		ruleSrc:       `field.Type == "int" || field.Name == "Missing" => "int64"`,
+		affectedTypes: true,
+		stderr:        &stderr,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Label is a string field, but it's not changed by the rule
+	want := "from int\nto int64\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWarnAPIBreak(t *testing.T) {
+	tests := []struct {
+		file string
+		from string
+		to   string
+		want string
+	}{
+		{
+			file: "api_break",
+			from: "int",
+			to:   "int64",
+			want: "test-fixtures/api_break.input:4:2: warning: changing exported field Exported.Field from int to int64 is a potential API break\n",
+		},
+		{
+			// the exported names of a group are warned about
+			file: "api_break_group",
+			from: "string",
+			to:   "[]byte",
+			want: "test-fixtures/api_break_group.input:4:5: warning: changing exported field Exported.Y from string to []byte is a potential API break\n",
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.file, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cfg := &config{
+				file:         filepath.Join(fixtureDir, ts.file+".input"),
+				all:          true,
+				from:         ts.from,
+				to:           ts.to,
+				warnAPIBreak: true,
+				stderr:       &stderr,
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := stderr.String(); got != ts.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestWriteChangesJSON(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_type_modify.input"),
+		structName: "foo",
+		fieldName:  "bar",
+		from:       "string",
+		to:         "[]byte",
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := writeChangesJSON(&out, cfg.changes); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[
+  {
+    "struct": "foo",
+    "field": "bar",
+    "from": "string",
+    "to": "[]byte",
+    "file": "test-fixtures/field_type_modify.input",
+    "line": 4,
+    "column": 2,
+    "offset": 32
+  }
+]
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteChangesJSONGroup(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "report_group.input"),
+		structName: "T",
+		from:       "string",
+		to:         "[]byte",
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := writeChangesJSON(&out, cfg.changes); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []change
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	// each name of a group has a record of its own
+	var got []string
+	for _, ch := range records {
+		got = append(got, fmt.Sprintf("%s:%d:%d", ch.Field, ch.Line, ch.Column))
+	}
+	want := []string{"x:4:2", "Y:4:5", "a:5:2", "b:5:5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+}
+
+func TestOutputJSONL(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       *config
+		wantLines int
+		// wantColumn is the column of the first record, if set
+		wantColumn int
+	}{
+		{
+			name: "fields",
+			cfg: &config{
+				file:       filepath.Join(fixtureDir, "field_type_modify.input"),
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+			},
+			wantLines: 3,
+		},
+		{
+			// the change of a split off field is streamed at its name in
+			// the original file
+			name: "split group",
+			cfg: &config{
+				file:       filepath.Join(fixtureDir, "field_group.input"),
+				structName: "foo",
+				fieldName:  "C",
+				from:       "string",
+				to:         "[]byte",
+			},
+			wantLines:  1,
+			wantColumn: 8,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			cfg := ts.cfg
+			cfg.outputFormat = outputJSONL
+			cfg.stdout = &stdout
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if len(lines) != len(cfg.changes) || len(lines) != ts.wantLines {
+				t.Fatalf("expected a line for each of the %d changes, got:\n%s", len(cfg.changes), stdout.String())
+			}
+			for i, line := range lines {
+				var ch change
+				if err := json.Unmarshal([]byte(line), &ch); err != nil {
+					t.Fatalf("line %d is not a JSON object: %s", i+1, err)
+				}
+				// the end offset isn't part of the JSON record
+				want := cfg.changes[i]
+				want.end = 0
+				if ch != want {
+					t.Errorf("line %d: got %+v, want %+v", i+1, ch, want)
+				}
+				if i == 0 && ts.wantColumn != 0 && ch.Column != ts.wantColumn {
+					t.Errorf("line %d: got column %d, want %d", i+1, ch.Column, ts.wantColumn)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteChangeSchema(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeChangeSchema(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Type  string `json:"type"`
+		Items struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+			Required []string `json:"required"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Type != "array" {
+		t.Errorf("got schema type %q, want array", schema.Type)
+	}
+
+	wantTypes := map[string]string{
+		"struct": "string",
+		"field":  "string",
+		"from":   "string",
+		"to":     "string",
+		"file":   "string",
+		"line":   "integer",
+		"column": "integer",
+		"offset": "integer",
+	}
+	if len(schema.Items.Properties) != len(wantTypes) {
+		t.Errorf("got %d properties, want %d", len(schema.Items.Properties), len(wantTypes))
+	}
+	for name, typ := range wantTypes {
+		if got := schema.Items.Properties[name].Type; got != typ {
+			t.Errorf("property %s: got type %q, want %q", name, got, typ)
+		}
+	}
+
+	wantRequired := []string{"field", "from", "to", "file", "line", "column", "offset"}
+	if !reflect.DeepEqual(schema.Items.Required, wantRequired) {
+		t.Errorf("got required %v, want %v", schema.Items.Required, wantRequired)
+	}
+}
+
+func TestWriteChangesSARIF(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_type_modify.input"),
+		structName: "foo",
+		fieldName:  "bar",
+		from:       "string",
+		to:         "[]byte",
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := writeChangesSARIF(&out, cfg.changes); err != nil {
+		t.Fatal(err)
+	}
+
+	var log map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log["version"] != "2.1.0" {
+		t.Errorf("got version %v, want 2.1.0", log["version"])
+	}
+
+	runs, _ := log["runs"].([]interface{})
+	if len(runs) != 1 {
+		t.Fatalf("expected a single run, got %v", log["runs"])
+	}
+	run := runs[0].(map[string]interface{})
+
+	driver := run["tool"].(map[string]interface{})["driver"].(map[string]interface{})
+	if driver["name"] != "gomodifytype" {
+		t.Errorf("got driver name %v", driver["name"])
+	}
+
+	results, _ := run["results"].([]interface{})
+	if len(results) != 1 {
+		t.Fatalf("expected a single result, got %v", run["results"])
+	}
+	result := results[0].(map[string]interface{})
+
+	if result["ruleId"] != sarifRuleID {
+		t.Errorf("got rule id %v, want %s", result["ruleId"], sarifRuleID)
+	}
+
+	message := result["message"].(map[string]interface{})["text"]
+	if message != "foo.bar changed from string to []byte" {
+		t.Errorf("got message %v", message)
+	}
+
+	location := result["locations"].([]interface{})[0].(map[string]interface{})["physicalLocation"].(map[string]interface{})
+	uri := location["artifactLocation"].(map[string]interface{})["uri"]
+	region := location["region"].(map[string]interface{})
+	if uri != "test-fixtures/field_type_modify.input" || region["startLine"] != 4.0 || region["startColumn"] != 2.0 {
+		t.Errorf("got location %v", location)
+	}
+}
+
+func TestWriteTypeGraph(t *testing.T) {
+	cfg := &config{
+		file:           filepath.Join(fixtureDir, "type_graph.input"),
+		all:            true,
+		from:           "string",
+		to:             "[]byte",
+		recurseStructs: true,
+		typeGraph:      true,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTypeGraph(&buf, cfg.changes); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	golden := filepath.Join(fixtureDir, "type_graph.golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTypeGraphValidation(t *testing.T) {
+	cfg := &config{
+		file:      filepath.Join(fixtureDir, "type_graph.input"),
+		all:       true,
+		from:      "string",
+		to:        "[]byte",
+		write:     true,
+		typeGraph: true,
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("expected -type-graph with -w to be rejected")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	changes := []change{
+		{Struct: "foo", Field: "A", From: "int", To: "int64", File: "a.go", Offset: 10},
+		{Struct: "foo", Field: "B", From: "int", To: "int64", File: "a.go", Offset: 20},
+		{Struct: "bar", Field: "C", From: "int", To: "int64", File: "a.go", Offset: 30},
+		{Struct: "foo", Field: "D", From: "string", To: "[]byte", File: "b.go", Offset: 10},
+	}
+
+	var buf bytes.Buffer
+	writeSummary(&buf, changes)
+
+	want := "gomodifytype: changed 4 fields in 3 structs across 2 files (int -> int64, string -> []byte)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	writeSummary(&buf, changes[:1])
+
+	want = "gomodifytype: changed 1 field in 1 struct across 1 file (int -> int64)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// each name of a group is counted
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "report_group.input"),
+		structName: "T",
+		from:       "string",
+		to:         "[]byte",
+	}
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	writeSummary(&buf, cfg.changes)
+
+	want = "gomodifytype: changed 4 fields in 1 struct across 1 file (string -> []byte)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWritePositions(t *testing.T) {
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "field_group.input"),
+		structName: "foo",
+		from:       "string",
+		to:         "[]byte",
+		positions:  true,
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writePositions(&buf, cfg.changes); err != nil {
+		t.Fatal(err)
+	}
+
+	// the spans cover the whole fields, tags included. Each name of a group
+	// spans from the name to the end of the group
+	want := "test-fixtures/field_group.input:73-98\ntest-fixtures/field_group.input:76-98\ntest-fixtures/field_group.input:79-98\n" +
+		"test-fixtures/field_group.input:129-143\ntest-fixtures/field_group.input:132-143\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReportCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config
+		want    string
+		wantErr error
+	}{
+		{
+			name: "changed",
+			cfg: &config{
+				file:       filepath.Join(fixtureDir, "field_group.input"),
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+			},
+			want: "modified 5 field(s)\n",
+		},
+		{
+			name: "no match",
+			cfg: &config{
+				file:       filepath.Join(fixtureDir, "field_group.input"),
+				structName: "foo",
+				from:       "nonexistent",
+				to:         "[]byte",
+			},
+			want:    "modified 0 field(s)\n",
+			wantErr: ErrNoMatch,
+		},
+		{
+			name: "declined",
+			cfg: &config{
+				file:       filepath.Join(fixtureDir, "field_group.input"),
+				structName: "foo",
+				from:       "string",
+				to:         "[]byte",
+				confirm:    confirmField,
+				stdin:      strings.NewReader("n\nn\n"),
+			},
+			want: "modified 0 field(s), declined 5\n",
+		},
+		{
+			name: "funcs",
+			cfg: &config{
+				file:  filepath.Join(fixtureDir, "funcs_params.input"),
+				all:   true,
+				scope: scopeFuncs,
+				from:  "context.Context",
+				to:    "Context",
+			},
+			want: "modified 2 parameter(s) or result(s)\n",
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cfg := ts.cfg
+			cfg.stderr = &stderr
+
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			// the -confirm prompts are not part of the count
+			stderr.Reset()
+			if err := cfg.reportCount(); !errors.Is(err, ts.wantErr) {
+				t.Errorf("got error %v, want %v", err, ts.wantErr)
+			}
+
+			if got := stderr.String(); got != ts.want {
+				t.Errorf("got %q, want %q", got, ts.want)
+			}
+
+			stderr.Reset()
+			cfg.quiet = true
+			if err := cfg.reportCount(); !errors.Is(err, ts.wantErr) {
+				t.Errorf("got error %v with -quiet, want %v", err, ts.wantErr)
+			}
+			if stderr.Len() != 0 {
+				t.Errorf("got %q with -quiet, want nothing", stderr.String())
+			}
+		})
+	}
+}
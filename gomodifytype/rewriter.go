@@ -0,0 +1,110 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"io"
+)
+
+// Options configures a Rewrite. One of Line, Struct or All selects the
+// fields to be processed, same as the -line, -struct and -all flags.
+type Options struct {
+	// Line is the line number of the field or a range of lines, i.e: 4
+	// or 4,8
+	Line string
+	// Struct is the name of the struct to be processed
+	Struct string
+	// Field is the name of the field to be processed, along with Struct
+	Field string
+	// All selects all structs
+	All bool
+
+	// From is the type of the fields to be changed
+	From string
+	// To is the type the fields are changed to
+	To string
+
+	// SkipUnexported skips unexported fields
+	SkipUnexported bool
+}
+
+// Change is a field type changed by a rewrite.
+type Change struct {
+	// Struct is the name of the struct declaring the field, it's empty for
+	// anonymous structs
+	Struct string
+	// Field is the name of the field
+	Field string
+	// From is the type of the field before the change
+	From string
+	// To is the type the field is changed to
+	To string
+	// Line and Column are the position of the field in the original source
+	Line   int
+	Column int
+}
+
+// Rewriter rewrites the field types of Go source files.
+type Rewriter struct {
+	// Stderr receives the warnings of the rewrite, os.Stderr is used if
+	// it's nil
+	Stderr io.Writer
+}
+
+// Rewrite changes the types of the fields selected by opts in src from
+// opts.From to opts.To, and returns the formatted source.
+func (r *Rewriter) Rewrite(src []byte, opts Options) ([]byte, error) {
+	out, _, err := r.rewrite(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// RewriteString is like Rewrite, but returns the original source along with
+// the rewritten one and the changes, i.e. for editor plugins showing both.
+func (r *Rewriter) RewriteString(src string, opts Options) (before, after string, changes []Change, err error) {
+	after, c, err := r.rewrite([]byte(src), opts)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	for _, ch := range c.changes {
+		changes = append(changes, Change{
+			Struct: ch.Struct,
+			Field:  ch.Field,
+			From:   ch.From,
+			To:     ch.To,
+			Line:   ch.Line,
+			Column: ch.Column,
+		})
+	}
+	return src, after, changes, nil
+}
+
+func (r *Rewriter) rewrite(src []byte, opts Options) (string, *config, error) {
+	c := &config{
+		file:                 stdinFile,
+		line:                 opts.Line,
+		structName:           opts.Struct,
+		fieldName:            opts.Field,
+		all:                  opts.All,
+		from:                 opts.From,
+		to:                   opts.To,
+		skipUnexportedFields: opts.SkipUnexported,
+		skipDirective:        defaultSkipDirective,
+		outputFormat:         outputText,
+		ensureFinalNewline:   true,
+		stdin:                bytes.NewReader(src),
+		stderr:               r.Stderr,
+	}
+
+	if err := c.validate(); err != nil {
+		return "", nil, err
+	}
+
+	out, err := c.process()
+	if err != nil {
+		return "", nil, err
+	}
+	return out, c, nil
+}
@@ -0,0 +1,169 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const rewriterSrc = `package foo
+
+type foo struct {
+	Name    string
+	private string
+	Count   int
+}
+
+type bar struct {
+	Name string
+}
+`
+
+func TestRewriterRewrite(t *testing.T) {
+	test := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "struct",
+			opts: Options{Struct: "bar", From: "string", To: "[]byte"},
+			want: `package foo
+
+type foo struct {
+	Name    string
+	private string
+	Count   int
+}
+
+type bar struct {
+	Name []byte
+}
+`,
+		},
+		{
+			name: "field",
+			opts: Options{Struct: "foo", Field: "Count", From: "int", To: "int64"},
+			want: `package foo
+
+type foo struct {
+	Name    string
+	private string
+	Count   int64
+}
+
+type bar struct {
+	Name string
+}
+`,
+		},
+		{
+			name: "line",
+			opts: Options{Line: "5", From: "string", To: "[]byte"},
+			want: `package foo
+
+type foo struct {
+	Name    string
+	private []byte
+	Count   int
+}
+
+type bar struct {
+	Name string
+}
+`,
+		},
+		{
+			name: "all skipping unexported",
+			opts: Options{All: true, From: "string", To: "[]byte", SkipUnexported: true},
+			want: `package foo
+
+type foo struct {
+	Name    []byte
+	private string
+	Count   int
+}
+
+type bar struct {
+	Name []byte
+}
+`,
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			var r Rewriter
+			got, err := r.Rewrite([]byte(rewriterSrc), ts.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != ts.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestRewriterRewriteErrors(t *testing.T) {
+	test := []struct {
+		name string
+		src  string
+		opts Options
+	}{
+		{
+			name: "no selection",
+			src:  rewriterSrc,
+			opts: Options{From: "string", To: "[]byte"},
+		},
+		{
+			name: "missing struct",
+			src:  rewriterSrc,
+			opts: Options{Struct: "missing", From: "string", To: "[]byte"},
+		},
+		{
+			name: "syntax error",
+			src:  "package foo\n\ntype foo struct {",
+			opts: Options{All: true, From: "string", To: "[]byte"},
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			r := Rewriter{Stderr: &bytes.Buffer{}}
+			if _, err := r.Rewrite([]byte(ts.src), ts.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRewriterRewriteString(t *testing.T) {
+	var r Rewriter
+	before, after, changes, err := r.RewriteString(rewriterSrc, Options{Struct: "bar", From: "string", To: "[]byte"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before != rewriterSrc {
+		t.Errorf("got before:\n%s\nwant:\n%s", before, rewriterSrc)
+	}
+	if after == before || !strings.Contains(after, "\tName []byte\n") {
+		t.Errorf("expected bar.Name to be changed, got:\n%s", after)
+	}
+
+	want := Change{Struct: "bar", Field: "Name", From: "string", To: "[]byte", Line: 10, Column: 2}
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("got changes %+v, want [%+v]", changes, want)
+	}
+
+	// nothing matches, the source is given back as is
+	before, after, changes, err = r.RewriteString(rewriterSrc, Options{Struct: "bar", From: "int", To: "int64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after || len(changes) != 0 {
+		t.Errorf("expected no change, got %+v and:\n%s", changes, after)
+	}
+}
@@ -0,0 +1,446 @@
+package gomodifytype
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rule is a parsed -rule value. Fields for which cond evaluates to true are
+// changed to the type to.
+//
+// The rule syntax is:
+//
+//	<condition> => "<type>"
+//
+// where the condition is an expression made of the field properties
+// field.Name, field.Type, field.Tag and field.Exported, string and boolean
+// literals, the ==, !=, !, && and || operators, parentheses and the
+// hasTag(field, "key") function, i.e:
+//
+//	field.Type == "int" && hasTag(field, "money") => "decimal.Decimal"
+type rule struct {
+	cond ruleExpr
+	to   string
+}
+
+// ruleField holds the properties of a struct field a rule is evaluated
+// against.
+type ruleField struct {
+	Name     string
+	Type     string
+	Tag      string
+	Exported bool
+}
+
+// newRuleField returns the rule properties of the given field, using name as
+// the field name.
+func newRuleField(f *ast.Field, name, typeString string) ruleField {
+	var tag string
+	if f.Tag != nil {
+		tag, _ = strconv.Unquote(f.Tag.Value)
+	}
+
+	return ruleField{
+		Name:     name,
+		Type:     typeString,
+		Tag:      tag,
+		Exported: isPublicName(name),
+	}
+}
+
+func (r *rule) match(f ruleField) bool {
+	return r.cond.eval(f).(bool)
+}
+
+type ruleKind int
+
+const (
+	kindString ruleKind = iota
+	kindBool
+	kindField
+)
+
+func (k ruleKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindBool:
+		return "bool"
+	default:
+		return "field"
+	}
+}
+
+// ruleExpr is a node of a rule condition. The kind of every node is known
+// after parsing, so evaluation can't fail.
+type ruleExpr interface {
+	kind() ruleKind
+	eval(f ruleField) interface{}
+}
+
+type ruleLit struct {
+	value interface{}
+}
+
+func (e ruleLit) kind() ruleKind {
+	if _, ok := e.value.(bool); ok {
+		return kindBool
+	}
+	return kindString
+}
+
+func (e ruleLit) eval(ruleField) interface{} { return e.value }
+
+type ruleFieldRef struct{}
+
+func (ruleFieldRef) kind() ruleKind { return kindField }
+
+func (ruleFieldRef) eval(f ruleField) interface{} { return f }
+
+type ruleProperty struct {
+	name string
+}
+
+func (e ruleProperty) kind() ruleKind {
+	if e.name == "Exported" {
+		return kindBool
+	}
+	return kindString
+}
+
+func (e ruleProperty) eval(f ruleField) interface{} {
+	switch e.name {
+	case "Name":
+		return f.Name
+	case "Type":
+		return f.Type
+	case "Tag":
+		return f.Tag
+	default:
+		return f.Exported
+	}
+}
+
+type ruleHasTag struct {
+	key ruleExpr
+}
+
+func (ruleHasTag) kind() ruleKind { return kindBool }
+
+func (e ruleHasTag) eval(f ruleField) interface{} {
+	_, ok := reflect.StructTag(f.Tag).Lookup(e.key.eval(f).(string))
+	return ok
+}
+
+type ruleNot struct {
+	x ruleExpr
+}
+
+func (ruleNot) kind() ruleKind { return kindBool }
+
+func (e ruleNot) eval(f ruleField) interface{} { return !e.x.eval(f).(bool) }
+
+type ruleBinary struct {
+	op   string
+	x, y ruleExpr
+}
+
+func (ruleBinary) kind() ruleKind { return kindBool }
+
+func (e ruleBinary) eval(f ruleField) interface{} {
+	switch e.op {
+	case "&&":
+		return e.x.eval(f).(bool) && e.y.eval(f).(bool)
+	case "||":
+		return e.x.eval(f).(bool) || e.y.eval(f).(bool)
+	case "==":
+		return e.x.eval(f) == e.y.eval(f)
+	default:
+		return e.x.eval(f) != e.y.eval(f)
+	}
+}
+
+// ruleToken is a lexical token of a rule. Only string literals have their
+// value unquoted.
+type ruleToken struct {
+	text   string
+	str    bool
+	offset int
+}
+
+func tokenizeRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(src); {
+		ch := rune(src[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '"' || ch == '`':
+			end := i + 1
+			for end < len(src) && src[end] != src[i] {
+				if src[i] == '"' && src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("rule: unterminated string at offset %d", i)
+			}
+			value, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("rule: invalid string at offset %d: %s", i, err)
+			}
+			tokens = append(tokens, ruleToken{text: value, str: true, offset: i})
+			i = end + 1
+		case ch == '_' || unicode.IsLetter(ch):
+			end := i
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, ruleToken{text: src[i:end], offset: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range []string{"=>", "==", "!=", "&&", "||", "!", "(", ")", ",", "."} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("rule: unexpected character %q at offset %d", ch, i)
+			}
+			tokens = append(tokens, ruleToken{text: op, offset: i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	size   int
+}
+
+// parseRule parses a -rule value.
+func parseRule(src string) (*rule, error) {
+	tokens, err := tokenizeRule(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ruleParser{tokens: tokens, size: len(src)}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if cond.kind() != kindBool {
+		return nil, fmt.Errorf("rule: condition must be a bool, got %s", cond.kind())
+	}
+
+	if err := p.expect("=>"); err != nil {
+		return nil, err
+	}
+
+	offset := p.offset()
+	to := p.next()
+	if to == nil || !to.str {
+		return nil, fmt.Errorf("rule: expected a quoted type after => at offset %d", offset)
+	}
+
+	if p.peek() != nil {
+		return nil, fmt.Errorf("rule: unexpected %q at offset %d", p.peek().text, p.offset())
+	}
+
+	return &rule{cond: cond, to: to.text}, nil
+}
+
+func (p *ruleParser) peek() *ruleToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() *ruleToken {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the next token is the given operator or keyword.
+func (p *ruleParser) is(text string) bool {
+	t := p.peek()
+	return t != nil && !t.str && t.text == text
+}
+
+// offset returns the offset of the next token, or the end of the source.
+func (p *ruleParser) offset() int {
+	if t := p.peek(); t != nil {
+		return t.offset
+	}
+	return p.size
+}
+
+func (p *ruleParser) expect(text string) error {
+	if !p.is(text) {
+		return fmt.Errorf("rule: expected %q at offset %d", text, p.offset())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	return p.parseBinary([]string{"&&"}, p.parseUnary)
+}
+
+// parseBinary parses a left associative chain of operands joined with one of
+// the logical operators. Both sides must be bools.
+func (p *ruleParser) parseBinary(ops []string, operand func() (ruleExpr, error)) (ruleExpr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := ""
+		for _, candidate := range ops {
+			if p.is(candidate) {
+				op = candidate
+			}
+		}
+		if op == "" {
+			return x, nil
+		}
+		offset := p.offset()
+		p.pos++
+
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		if x.kind() != kindBool || y.kind() != kindBool {
+			return nil, fmt.Errorf("rule: %s needs bool operands at offset %d", op, offset)
+		}
+		x = ruleBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if p.is("!") {
+		offset := p.offset()
+		p.pos++
+
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindBool {
+			return nil, fmt.Errorf("rule: ! needs a bool operand at offset %d", offset)
+		}
+		return ruleNot{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *ruleParser) parseComparison() (ruleExpr, error) {
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.is("==") && !p.is("!=") {
+		return x, nil
+	}
+	offset := p.offset()
+	op := p.next().text
+
+	y, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if x.kind() != y.kind() || x.kind() == kindField {
+		return nil, fmt.Errorf("rule: can't compare %s with %s at offset %d", x.kind(), y.kind(), offset)
+	}
+	return ruleBinary{op: op, x: x, y: y}, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleExpr, error) {
+	offset := p.offset()
+	t := p.next()
+	if t == nil {
+		return nil, fmt.Errorf("rule: unexpected end of rule at offset %d", offset)
+	}
+
+	if t.str {
+		return ruleLit{value: t.text}, nil
+	}
+
+	switch t.text {
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case "true", "false":
+		return ruleLit{value: t.text == "true"}, nil
+	case "field":
+		if !p.is(".") {
+			return ruleFieldRef{}, nil
+		}
+		p.pos++
+
+		nameOffset := p.offset()
+		name := p.next()
+		if name == nil || name.str {
+			return nil, fmt.Errorf("rule: expected a field property at offset %d", nameOffset)
+		}
+		switch name.text {
+		case "Name", "Type", "Tag", "Exported":
+			return ruleProperty{name: name.text}, nil
+		}
+		return nil, fmt.Errorf("rule: unknown field property %q at offset %d", name.text, nameOffset)
+	case "hasTag":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if arg.kind() != kindField {
+			return nil, fmt.Errorf("rule: hasTag expects field as its first argument at offset %d", offset)
+		}
+
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+
+		key, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if key.kind() != kindString {
+			return nil, fmt.Errorf("rule: hasTag expects a string key at offset %d", offset)
+		}
+
+		return ruleHasTag{key: key}, p.expect(")")
+	}
+
+	return nil, fmt.Errorf("rule: unexpected %q at offset %d", t.text, offset)
+}
@@ -0,0 +1,100 @@
+package gomodifytype
+
+import (
+	"testing"
+)
+
+func TestRuleMatch(t *testing.T) {
+	money := ruleField{Name: "Price", Type: "int", Tag: `json:"price" money:"usd"`, Exported: true}
+	count := ruleField{Name: "count", Type: "int", Tag: `json:"count"`}
+	label := ruleField{Name: "Label", Type: "string", Exported: true}
+
+	test := []struct {
+		rule string
+		want []bool // for money, count and label
+	}{
+		{
+			rule: `field.Type == "int" && hasTag(field, "money") => "decimal.Decimal"`,
+			want: []bool{true, false, false},
+		},
+		{
+			rule: `field.Type == "int" => "int64"`,
+			want: []bool{true, true, false},
+		},
+		{
+			rule: `field.Exported && !(field.Type == "int") => "[]byte"`,
+			want: []bool{false, false, true},
+		},
+		{
+			rule: `field.Name == "count" || field.Tag == "" => "int64"`,
+			want: []bool{false, true, true},
+		},
+		{
+			rule: "field.Exported != true && hasTag(field, `json`) => `uint`",
+			want: []bool{false, true, false},
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.rule, func(t *testing.T) {
+			r, err := parseRule(ts.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, f := range []ruleField{money, count, label} {
+				if got := r.match(f); got != ts.want[i] {
+					t.Errorf("field %s: got %t, want %t", f.Name, got, ts.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	test := []struct {
+		rule    string
+		wantErr string
+	}{
+		{
+			rule:    `field.Type == "int"`,
+			wantErr: `rule: expected "=>" at offset 19`,
+		},
+		{
+			rule:    `field.Type => "int"`,
+			wantErr: "rule: condition must be a bool, got string",
+		},
+		{
+			rule:    `field.Type == true => "int"`,
+			wantErr: "rule: can't compare string with bool at offset 11",
+		},
+		{
+			rule:    `field.Size == "8" => "int"`,
+			wantErr: `rule: unknown field property "Size" at offset 6`,
+		},
+		{
+			rule:    `hasTag("json") => "int"`,
+			wantErr: "rule: hasTag expects field as its first argument at offset 0",
+		},
+		{
+			rule:    `field.Exported => int`,
+			wantErr: "rule: expected a quoted type after => at offset 18",
+		},
+		{
+			rule:    `field.Name == "a => "int"`,
+			wantErr: "rule: unterminated string at offset 24",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.rule, func(t *testing.T) {
+			_, err := parseRule(ts.rule)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != ts.wantErr {
+				t.Errorf("got error %q, want %q", err, ts.wantErr)
+			}
+		})
+	}
+}
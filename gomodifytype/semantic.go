@@ -0,0 +1,347 @@
+package gomodifytype
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"runtime"
+	"strings"
+)
+
+// typeCheck type checks the parsed file so field types can be resolved to
+// their go/types representation. Errors are ignored on purpose: a single
+// file is usually only a part of a package and might reference declarations
+// which aren't available to us. Whatever could be resolved is kept in c.info.
+func (c *config) typeCheck(file *ast.File) {
+	c.info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+
+	c.parsed = file
+
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(error) {},
+	}
+	c.pkg, _ = conf.Check(file.Name.Name, c.fileSet, []*ast.File{file}, c.info)
+}
+
+// checkImportConflict detects imports renamed to a builtin type name which
+// is used by -from, i.e: import string "strings" with -from string. The
+// builtin is shadowed in such a file, so -from is ambiguous. In semantic mode
+// that's an error and a qualified -from is required, otherwise it's a
+// warning.
+func (c *config) checkImportConflict(node ast.Node) error {
+	file, ok := node.(*ast.File)
+	if !ok {
+		return nil
+	}
+
+	for _, pair := range c.typePairs() {
+		if pair.from == "" {
+			continue
+		}
+		if err := c.checkFromImportConflict(file, pair.from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *config) checkFromImportConflict(file *ast.File, fromType string) error {
+	from, err := parser.ParseExpr(fromType)
+	if err != nil {
+		return nil
+	}
+
+	for _, spec := range file.Imports {
+		if spec.Name == nil {
+			continue
+		}
+
+		name := spec.Name.Name
+		if _, ok := types.Universe.Lookup(name).(*types.TypeName); !ok {
+			continue
+		}
+
+		used := false
+		ast.Inspect(from, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+				used = true
+			}
+			return !used
+		})
+		if !used {
+			continue
+		}
+
+		if c.semantic {
+			return fmt.Errorf("-from %q is ambiguous, the builtin %s is shadowed by the import of %s at %s, qualify the type instead",
+				fromType, name, spec.Path.Value, c.fileSet.Position(spec.Pos()))
+		}
+		c.warnf(spec.Pos(), "-from %q is ambiguous, the builtin %s is shadowed by the import of %s", fromType, name, spec.Path.Value)
+	}
+	return nil
+}
+
+// typeCandidate is a distinct type a -from value resolved to, along with the
+// first field which uses it.
+type typeCandidate struct {
+	typ   types.Type
+	field *ast.Field
+}
+
+// checkAmbiguousFrom returns an error if fields between the start and end
+// lines which match -from resolve to more than one distinct type. This
+// happens when a builtin or package level type is shadowed by a local
+// declaration with the same name.
+func (c *config) checkAmbiguousFrom(node ast.Node, start, end int) error {
+	for _, pair := range c.typePairs() {
+		if err := c.checkAmbiguousType(node, start, end, pair.from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *config) checkAmbiguousType(node ast.Node, start, end int, from string) error {
+	var candidates []typeCandidate
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		x, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+
+		for _, f := range x.Fields.List {
+			line := c.fileSet.Position(f.Pos()).Line
+			if !(start <= line && line <= end) {
+				continue
+			}
+
+			if !c.syntacticMatch(f.Type, from) {
+				continue
+			}
+
+			typ := c.info.TypeOf(f.Type)
+			if typ == nil {
+				continue
+			}
+
+			known := false
+			for _, cand := range candidates {
+				if types.Identical(cand.typ, typ) {
+					known = true
+					break
+				}
+			}
+			if !known {
+				candidates = append(candidates, typeCandidate{typ: typ, field: f})
+			}
+		}
+		return true
+	})
+
+	if len(candidates) < 2 {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-from %q is ambiguous, it matches %d different types:", from, len(candidates))
+	for _, cand := range candidates {
+		fmt.Fprintf(&b, "\n\t%s, used at %s", c.describeType(cand.typ), c.fileSet.Position(cand.field.Type.Pos()))
+	}
+	return errors.New(b.String())
+}
+
+// describeType returns a human readable description of the type including
+// the position of its declaration, if it has one.
+func (c *config) describeType(typ types.Type) string {
+	named, ok := typ.(*types.Named)
+	if !ok || !named.Obj().Pos().IsValid() {
+		return fmt.Sprintf("%s (builtin)", typ)
+	}
+	return fmt.Sprintf("%s declared at %s", typ, c.fileSet.Position(named.Obj().Pos()))
+}
+
+// semanticMatch reports whether the type expression is identical to the from
+// type, resolved in the scope of the expression. This makes equivalent
+// spellings, such as []byte and []uint8, match each other. ok is false if any
+// of the types couldn't be resolved.
+func (c *config) semanticMatch(t ast.Expr, fromType string) (match, ok bool) {
+	typ := c.info.TypeOf(t)
+	if typ == nil || c.pkg == nil {
+		return false, false
+	}
+
+	from, err := types.Eval(c.fileSet, c.pkg, t.Pos(), fromType)
+	if err != nil || !from.IsType() {
+		return false, false
+	}
+
+	return types.Identical(typ, from.Type), true
+}
+
+// underlyingMatch reports whether the type expression resolves to a named
+// type whose underlying type is identical to -from-underlying, i.e: a
+// "type Email string" field for -from-underlying string.
+func (c *config) underlyingMatch(t ast.Expr) bool {
+	named, ok := c.info.TypeOf(t).(*types.Named)
+	if !ok || c.pkg == nil {
+		return false
+	}
+
+	underlying, err := types.Eval(c.fileSet, c.pkg, t.Pos(), c.fromUnderlying)
+	if err != nil || !underlying.IsType() {
+		return false
+	}
+
+	return types.Identical(named.Underlying(), underlying.Type)
+}
+
+// sizeMatch reports whether the type expression resolves to a type of
+// -from-size bytes, using the sizes of the gc compiler for the current
+// architecture.
+func (c *config) sizeMatch(t ast.Expr) bool {
+	typ := c.info.TypeOf(t)
+	if typ == nil {
+		return false
+	}
+
+	sizes := types.SizesFor("gc", runtime.GOARCH)
+	if sizes == nil {
+		return false
+	}
+	return sizes.Sizeof(typ) == c.fromSize
+}
+
+// checkTarget warns if an unqualified -to type doesn't resolve in the scope
+// of the replaced type expression. Package level types resolve regardless of
+// where they're declared in the file. Qualified types aren't checked, as the
+// package might not be imported yet. Each type is only reported once.
+func (c *config) checkTarget(t ast.Expr, to string) {
+	if c.pkg == nil || strings.Contains(to, ".") || c.checkedTargets[to] {
+		return
+	}
+
+	if c.checkedTargets == nil {
+		c.checkedTargets = make(map[string]bool)
+	}
+	c.checkedTargets[to] = true
+
+	tv, err := types.Eval(c.fileSet, c.pkg, t.Pos(), to)
+	if err != nil {
+		if terr, ok := err.(types.Error); ok {
+			err = errors.New(terr.Msg)
+		}
+		c.warnf(t.Pos(), "-to %q doesn't resolve: %s", to, err)
+	} else if !tv.IsType() {
+		c.warnf(t.Pos(), "-to %q is not a type", to)
+	}
+}
+
+// namedStruct resolves the named type of the expression, following pointers
+// and slices, and returns its struct declaration if it's declared in the
+// file.
+func (c *config) namedStruct(file ast.Node, t ast.Expr) *ast.StructType {
+	typ := c.info.TypeOf(deref(t))
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil
+	}
+
+	var st *ast.StructType
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok || spec.Name.Pos() != named.Obj().Pos() {
+			return st == nil
+		}
+		st, _ = spec.Type.(*ast.StructType)
+		return false
+	})
+	return st
+}
+
+// rewriteEmbedded rewrites the fields of a struct embedded by value or by
+// pointer, if it's declared in the file. Structs declared in other packages
+// are out of reach of a single file run, they are reported instead.
+func (c *config) rewriteEmbedded(f *ast.Field) {
+	named, ok := c.info.TypeOf(deref(f.Type)).(*types.Named)
+	if !ok {
+		return
+	}
+
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return
+	}
+
+	if named.Obj().Pkg() != c.pkg {
+		c.warnf(f.Pos(), "embedded struct %s is declared in another package, its fields are not rewritten", types.ExprString(f.Type))
+		return
+	}
+
+	if st := c.namedStruct(c.parsed, f.Type); st != nil {
+		c.rewriteNested(st)
+	}
+}
+
+// rewriteConstraints rewrites the constraints of the type parameters of a
+// generic struct which are used as the type of the selected fields. Only the
+// constraint terms matching -retype-constraint are changed, i.e: for
+// Old=New, the field V T of Box[T ~Old | int] gives Box[T ~New | int].
+func (c *config) rewriteConstraints(spec *ast.TypeSpec, start, end int) {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || spec.TypeParams == nil {
+		return
+	}
+
+	for _, f := range st.Fields.List {
+		if !c.inSelection(f, start, end) {
+			continue
+		}
+
+		ident, ok := deref(f.Type).(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		obj := c.info.Uses[ident]
+		if obj == nil {
+			continue
+		}
+
+		for _, param := range spec.TypeParams.List {
+			for _, name := range param.Names {
+				if c.info.Defs[name] == obj {
+					c.rewriteConstraintTerm(spec.Name.Name, name.Name, &param.Type)
+				}
+			}
+		}
+	}
+}
+
+// rewriteConstraintTerm replaces the constraint terms matching the old type,
+// either as is or with a tilde, descending into unions.
+func (c *config) rewriteConstraintTerm(structName, param string, t *ast.Expr) {
+	switch x := (*t).(type) {
+	case *ast.BinaryExpr:
+		if x.Op == token.OR {
+			c.rewriteConstraintTerm(structName, param, &x.X)
+			c.rewriteConstraintTerm(structName, param, &x.Y)
+		}
+	case *ast.UnaryExpr:
+		if x.Op == token.TILDE {
+			c.rewriteConstraintTerm(structName, param, &x.X)
+		}
+	default:
+		if types.ExprString(*t) == c.constraintFrom {
+			c.replaceExpr(structName, param, *t, t, c.constraintTo)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package gomodifytype
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAbortOnAmbiguousFrom(t *testing.T) {
+	test := []struct {
+		name    string
+		line    string
+		wantErr []string
+	}{
+		{
+			name: "shadowed",
+			line: "1,14",
+			wantErr: []string{
+				`-from "string" is ambiguous, it matches 2 different types:`,
+				"string (builtin), used at test-fixtures/ambiguous_from.input:4:6",
+				"foo.string declared at test-fixtures/ambiguous_from.input:8:7, used at test-fixtures/ambiguous_from.input:11:7",
+			},
+		},
+		{
+			name: "not shadowed",
+			line: "1,5",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.name, func(t *testing.T) {
+			cfg := &config{
+				file:                 filepath.Join(fixtureDir, "ambiguous_from.input"),
+				line:                 ts.line,
+				from:                 "string",
+				to:                   "[]byte",
+				semantic:             true,
+				abortOnAmbiguousFrom: true,
+			}
+
+			_, err := cfg.process()
+			if len(ts.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an ambiguity error")
+			}
+			for _, want := range ts.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSemanticMatchUint8(t *testing.T) {
+	cfg := &config{
+		file:     filepath.Join(fixtureDir, "semantic_byte_alias.input"),
+		all:      true,
+		from:     "[]uint8",
+		to:       "Raw",
+		semantic: true,
+	}
+
+	out, err := cfg.process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"a Raw", "b Raw", "c []int8"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	test := []struct {
+		to   string
+		want string
+	}{
+		{
+			// declared after its use
+			to: "UserID",
+		},
+		{
+			to: "pkg.Unknown",
+		},
+		{
+			to:   "Missing",
+			want: "test-fixtures/to_later_type.input:4:7: warning: -to \"Missing\" doesn't resolve: undefined: Missing\n",
+		},
+	}
+
+	for _, ts := range test {
+		t.Run(ts.to, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cfg := &config{
+				file:       filepath.Join(fixtureDir, "to_later_type.input"),
+				structName: "User",
+				from:       "string",
+				to:         ts.to,
+				semantic:   true,
+				stderr:     &stderr,
+			}
+
+			if _, err := cfg.process(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := stderr.String(); got != ts.want {
+				t.Errorf("got warnings %q, want %q", got, ts.want)
+			}
+		})
+	}
+}
+
+func TestCheckTargetPackageMain(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:       filepath.Join(fixtureDir, "package_main.input"),
+		structName: "message",
+		from:       "[]byte",
+		to:         "Raw",
+		semantic:   true,
+		stderr:     &stderr,
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the types of the main package resolve as in any other package
+	if got := stderr.String(); got != "" {
+		t.Errorf("got warnings %q, want none", got)
+	}
+
+	if pkg := cfg.pkg.Name(); pkg != "main" {
+		t.Errorf("got package %q, want main", pkg)
+	}
+}
+
+func TestCheckImportConflict(t *testing.T) {
+	for _, semantic := range []bool{false, true} {
+		var stderr bytes.Buffer
+		cfg := &config{
+			file:       filepath.Join(fixtureDir, "import_shadows_builtin.input"),
+			structName: "foo",
+			from:       "string",
+			to:         "Text",
+			semantic:   semantic,
+			stderr:     &stderr,
+		}
+
+		_, err := cfg.process()
+		if semantic {
+			want := `-from "string" is ambiguous, the builtin string is shadowed by the import of "strings" at test-fixtures/import_shadows_builtin.input:3:8, qualify the type instead`
+			if err == nil || err.Error() != want {
+				t.Errorf("semantic: got error %v, want %q", err, want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `test-fixtures/import_shadows_builtin.input:3:8: warning: -from "string" is ambiguous, the builtin string is shadowed by the import of "strings"` + "\n"
+		if got := stderr.String(); got != want {
+			t.Errorf("got warning:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestRewriteEmbeddedOtherPackage(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := &config{
+		file:           filepath.Join(fixtureDir, "recurse_embedded.input"),
+		structName:     "foo",
+		from:           "string",
+		to:             "Text",
+		recurseStructs: true,
+		semantic:       true,
+		stderr:         &stderr,
+	}
+
+	if _, err := cfg.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test-fixtures/recurse_embedded.input:8:2: warning: embedded struct sync.Mutex is declared in another package, its fields are not rewritten\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
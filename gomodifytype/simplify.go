@@ -0,0 +1,218 @@
+package gomodifytype
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+// simplify applies the simplifications of gofmt -s to the file:
+//
+//	[]T{T{}, T{}}          becomes  []T{{}, {}}
+//	map[K]*T{K{}: &T{}}    becomes  map[K]*T{{}: {}}
+//	s[a:len(s)]            becomes  s[a:]
+//	for x, _ = range v     becomes  for x = range v
+//	for _ = range v        becomes  for range v
+//	const ()               is removed
+//
+// The logic is ported from gofmt, types are compared as written, i.e: byte
+// and uint8 are different.
+func simplify(node ast.Node) {
+	var dotImport bool
+	if file, ok := node.(*ast.File); ok {
+		removeEmptyDeclGroups(file)
+		for _, spec := range file.Imports {
+			if spec.Name != nil && spec.Name.Name == "." {
+				dotImport = true
+			}
+		}
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			simplifyCompositeLit(x)
+		case *ast.SliceExpr:
+			// len might come from the dot imported package
+			if !dotImport {
+				simplifySliceExpr(x)
+			}
+		case *ast.RangeStmt:
+			if isBlank(x.Value) {
+				x.Value = nil
+			}
+			if isBlank(x.Key) && x.Value == nil {
+				x.Key = nil
+				x.Tok = token.ILLEGAL
+			}
+		}
+		return true
+	})
+}
+
+// simplifyCompositeLit removes the element types of an array, slice or map
+// literal which are implied by its type.
+func simplifyCompositeLit(lit *ast.CompositeLit) {
+	var keyType, eltType ast.Expr
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		eltType = t.Elt
+	case *ast.MapType:
+		keyType = t.Key
+		eltType = t.Value
+	default:
+		return
+	}
+
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if keyType != nil {
+				kv.Key = simplifyElement(kv.Key, keyType)
+			}
+			kv.Value = simplifyElement(kv.Value, eltType)
+			continue
+		}
+		lit.Elts[i] = simplifyElement(elt, eltType)
+	}
+}
+
+// simplifyElement removes the type of a composite literal element, or the
+// address operator along with it, if the type is the element type.
+func simplifyElement(x, eltType ast.Expr) ast.Expr {
+	if lit, ok := x.(*ast.CompositeLit); ok && lit.Type != nil && match(reflect.ValueOf(eltType), reflect.ValueOf(lit.Type)) {
+		lit.Type = nil
+		return lit
+	}
+
+	star, ok := eltType.(*ast.StarExpr)
+	if !ok {
+		return x
+	}
+
+	addr, ok := x.(*ast.UnaryExpr)
+	if !ok || addr.Op != token.AND {
+		return x
+	}
+
+	if lit, ok := addr.X.(*ast.CompositeLit); ok && lit.Type != nil && match(reflect.ValueOf(star.X), reflect.ValueOf(lit.Type)) {
+		lit.Type = nil
+		return lit
+	}
+	return x
+}
+
+// simplifySliceExpr removes a high bound which is the length of the sliced
+// variable, i.e: s[a:len(s)]. len must be the builtin, which isn't resolved
+// to a declaration of the file.
+func simplifySliceExpr(x *ast.SliceExpr) {
+	s, ok := x.X.(*ast.Ident)
+	if !ok || x.Slice3 {
+		return
+	}
+
+	call, ok := x.High.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+		return
+	}
+
+	fun, ok := call.Fun.(*ast.Ident)
+	if !ok || fun.Name != "len" || fun.Obj != nil {
+		return
+	}
+
+	arg, ok := call.Args[0].(*ast.Ident)
+	if ok && arg.Name == s.Name {
+		x.High = nil
+	}
+}
+
+var (
+	identType     = reflect.TypeOf((*ast.Ident)(nil))
+	objectPtrType = reflect.TypeOf((*ast.Object)(nil))
+	callExprType  = reflect.TypeOf((*ast.CallExpr)(nil))
+)
+
+// match reports whether the two AST values are the same, as gofmt compares
+// them. Positions and resolved objects are ignored.
+func match(x, y reflect.Value) bool {
+	if !x.IsValid() || !y.IsValid() {
+		return !x.IsValid() && !y.IsValid()
+	}
+	if x.Type() != y.Type() {
+		return false
+	}
+
+	switch x.Type() {
+	case identType:
+		a, b := x.Interface().(*ast.Ident), y.Interface().(*ast.Ident)
+		return a == nil && b == nil || a != nil && b != nil && a.Name == b.Name
+	case objectPtrType, posType:
+		return true
+	case callExprType:
+		// f(x) and f(x...) differ only by the position of the ellipsis
+		a, b := x.Interface().(*ast.CallExpr), y.Interface().(*ast.CallExpr)
+		if a != nil && b != nil && a.Ellipsis.IsValid() != b.Ellipsis.IsValid() {
+			return false
+		}
+	}
+
+	x, y = reflect.Indirect(x), reflect.Indirect(y)
+	if !x.IsValid() || !y.IsValid() {
+		return !x.IsValid() && !y.IsValid()
+	}
+
+	switch x.Kind() {
+	case reflect.Slice:
+		if x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !match(x.Index(i), y.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			if !match(x.Field(i), y.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		return match(x.Elem(), y.Elem())
+	}
+
+	// tokens, literal values and the like
+	return x.Interface() == y.Interface()
+}
+
+// removeEmptyDeclGroups removes the declaration groups without specs, i.e:
+// const (), unless they have comments.
+func removeEmptyDeclGroups(file *ast.File) {
+	i := 0
+	for _, d := range file.Decls {
+		if g, ok := d.(*ast.GenDecl); !ok || !isEmptyDecl(file, g) {
+			file.Decls[i] = d
+			i++
+		}
+	}
+	file.Decls = file.Decls[:i]
+}
+
+func isEmptyDecl(file *ast.File, g *ast.GenDecl) bool {
+	if g.Doc != nil || g.Specs != nil {
+		return false
+	}
+	for _, c := range file.Comments {
+		if g.Pos() <= c.Pos() && c.End() <= g.End() {
+			return false
+		}
+	}
+	return true
+}
+
+func isBlank(x ast.Expr) bool {
+	ident, ok := x.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
@@ -0,0 +1,67 @@
+package gomodifytype
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// loadSyncTypes parses the -sync-from file and returns the types of the
+// fields of the named struct, by field name. Embedded fields are named
+// after their type, i.e. Buffer for *bytes.Buffer. The struct must be
+// declared once in the file.
+func loadSyncTypes(file, structName string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*structType
+	for _, s := range collectStructs(node, true) {
+		if s.name == structName {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%s: struct %s does not exist", file, structName)
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].node.Pos() < matched[j].node.Pos() })
+	if err := checkAmbiguousStructs(fset, matched); err != nil {
+		if ambiguous, ok := err.(*ambiguousStructError); ok {
+			ambiguous.file = file
+		}
+		return nil, err
+	}
+	st := matched[0].node
+
+	fields := make(map[string]string)
+	for _, f := range st.Fields.List {
+		typ := types.ExprString(f.Type)
+		if f.Names == nil {
+			if name := embeddedName(f.Type); name != "" {
+				fields[name] = typ
+			}
+			continue
+		}
+		for _, name := range f.Names {
+			fields[name.Name] = typ
+		}
+	}
+	return fields, nil
+}
+
+// rewriteSynced changes the type of the field to the type of the field with
+// the same name in the -sync-from struct, if they differ. Fields the
+// reference struct doesn't have are kept.
+func (c *config) rewriteSynced(f *ast.Field, name string) {
+	to, ok := c.synced[name]
+	if !ok || to == types.ExprString(f.Type) {
+		return
+	}
+	c.replaceType(f, name, to)
+}